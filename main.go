@@ -1,344 +1,621 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"usipProject/netscaler"
 )
 
-// Server is a data structure for NetScaler server data.
-type Server struct {
-	name      string
-	ipAddress string
-}
+// Version is the version of the program printed by the -version flag.  Release builds set it with
+// -ldflags "-X main.Version=<version>".
+var Version = "dev"
 
-type Service struct {
-	name     string
-	server   Server
-	protocol string
-	port     string
-	usip     string
-}
-
-// GetFile is a function that gets access to a file based on the file name.
-func GetFile(fileName string) (string, error) {
-	file, err := ioutil.ReadFile(fileName)
+// CreateFile is a function that accepts a file name as a parameter and returns a pointer to a file.  An existing file
+// is truncated so that running the program again replaces the previous output rather than adding to it.
+func CreateFile(fileName string) (*os.File, error) {
+	file, err := os.OpenFile(fileName, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(file), nil
+	return file, nil
 }
 
-// GetConfig is a function that takes the contents of a file as a parameter as well as
-// a pattern to use as a filter to return results as strings.
-func GetConfig(file, pattern string) ([]string, error) {
-	regexer, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, err
+// main contains the business logic of the program.  It writes the Load Balancing service name, server name and server
+// IP address of services, including service group members, that are using usip (use source IP address).  The -format
+// flag selects between plain text, in which names containing spaces are quoted, an aligned table, a JSON array,
+// newline-delimited JSON, CSV, TSV, an HTML report and a SQLite database written to the -o file; the table is the
+// default on a terminal and plain text everywhere else, and the -columns flag chooses the columns of the table, CSV and
+// TSV output.  The -usip flag selects services by their usip value, the -state flag by whether they are enabled or
+// disabled, the -server flag by the name of their server, the -cidr flag by its network, the -nonstandard-ports flag
+// drops services on ports 80 and 443, the -inconsistent flag selects every service of the servers that have services
+// both with and without USIP enabled, and the -exclude flag drops services whose name matches a regular expression.
+// The services are written sorted by the -sort key, their name by default.  The -ips-only flag instead writes the
+// sorted, unique IP addresses of the servers of services with USIP enabled, one per line, for firewall rules.  The
+// output is written to stdout unless the -o flag names a file; "-o auto" writes to <file>-usip-output.txt next to the
+// input as earlier versions did, and -output-dir writes one such file for each input to a directory instead.  A file
+// name may also be an http or https URL, in which case the configuration is fetched from it.  When no file name is
+// given the configuration is read from stdin.  The -list flag names a file listing further file names, one per line,
+// with blank lines and # comments ignored.  When several file names are given their services are combined; a file that
+// cannot be parsed is reported without stopping the others, and -progress reports each file as it is parsed.  The
+// -limit flag stops parsing once that many services have been read, to peek at the start of a large configuration.
+// Configurations are read as UTF-8, ignoring a leading byte order mark, unless -encoding latin1 says they were saved in
+// Latin-1.  The -quiet flag silences the warnings, summary and log output written to stderr, leaving only errors.
+//
+// Running "usip -check <file>" only reports the issues found while parsing, such as malformed lines and missing
+// servers, and exits with a non-zero status if there are any.  Running "usip -diff <old> <new>" instead lists the
+// services that gained or lost USIP between two versions of a configuration, and "usip dump <file>" writes the entire
+// parsed configuration to stdout as JSON.  "usip -watch <file>" lists the services using USIP and then keeps running,
+// checking the file every -interval and listing the services that gained or lost USIP each time it changes, until it is
+// interrupted.  "usip -version" prints the version of the program.
+//
+// Any error is written to stderr and the program exits with a non-zero status.  For use as a monitoring check,
+// -check-mode sets the exit status to 0 when no services use USIP, 1 when some do and 2 when a file cannot be parsed.
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code := 1
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		os.Exit(code)
 	}
-	results := regexer.FindAllString(file, -1)
-	return results, nil
 }
 
-// RemoveConfigKeywords is a function that removes the CLI keywords from within a NetScaler configuration.
-func RemoveConfigKeywords(textLine, pattern string) string {
-	result := strings.Replace(textLine, pattern, "", 1)
-	return result
-}
+// The exit statuses of -check-mode, which follow the convention of monitoring checks.
+const (
+	exitNoUSIP     = 0
+	exitUSIPFound  = 1
+	exitParseError = 2
+)
 
-// QuoteIndex is a function that returns a 2D slice of integers.  This function helps to determine if there is a
-// quote within a slice of strings.  If there is a quote this means that there is a space within a string and will
-// have to be dealt with.  At the point in which this function is called, there may or may not be a quote in the first
-// position of the string which is accepted as the parameter.
-func QuoteIndex(line string) ([][]int, error) {
-	pattern := "\"[a-zA-Z_0-9!<(/ ].*?[a-zA-Z_0-9)^\" /.']\""
-	regexer, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, err
-	}
-	result := regexer.FindAllStringIndex(line, 1)
-	return result, nil
+// exitError is a data structure for an error returned by run that sets a particular exit status.
+type exitError struct {
+	code int
+	err  error
 }
 
-// ExtractQuote is a function that uses a regular expression to extract strings that are surrounded by quotes.
-// This function returns a string with the quotes.
-func ExtractQuote(line string) (string, error) {
-	pattern := "\"[a-zA-Z_0-9!<(/ ].*?[a-zA-Z_0-9)^\" /.']\""
-	regexer, err := regexp.Compile(pattern)
-	if err != nil {
-		return "", err
-	}
-	result := regexer.FindString(line)
-	return result, nil
+// Error is a method that returns the message of the underlying error.
+func (e *exitError) Error() string {
+	return e.err.Error()
 }
 
-// RemoveQuote is a function that removes quotes from a string.
-func RemoveQuote(line string) string {
-	pattern := "\""
-	result := strings.Replace(line, pattern, "", -1)
-	return result
+// Unwrap is a method that returns the underlying error.
+func (e *exitError) Unwrap() error {
+	return e.err
 }
 
-// ExtractNoQuote is a function that extracts the first string followed by a space, that does not
-// include a quote within the string.  While not intuitive, it works well with how the NetScaler config
-// file is constructed.
-func ExtractNoQuote(line string) (string, error) {
-	pattern := "[A-Za-z0-9._].*?\\s"
-	regexer, err := regexp.Compile(pattern)
-	if err != nil {
-		return "", err
+// run is a function that parses the command line arguments args, reads the configuration and writes the output.  It
+// returns the first error encountered so that main can report it and set the exit status.  When no file name is given
+// and stdin is a terminal rather than a pipe, there is nothing to read, so the usage is printed and an error returned.
+// With -check-mode any error is returned as an exitError with the parse error status, and finding services with USIP
+// enabled is returned as one with the USIP found status.
+func run(args []string) (err error) {
+	flags := flag.NewFlagSet("usip", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: usip [flags] [file ...]")
+		fmt.Fprintln(flags.Output(), "       usip -diff <old> <new>")
+		fmt.Fprintln(flags.Output(), "       usip -watch [-interval <duration>] <file>")
+		fmt.Fprintln(flags.Output(), "       usip dump <file>")
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "exit status with -check-mode: 0 no USIP services, 1 USIP services found, 2 parse error")
 	}
-	result := regexer.FindString(line)
-	return result, nil
-}
-
-// BuildServer is a function that accepts a file name as a parameter as well as server name as a string and returns a
-// single Server type.
-func BuildServer(fileName, serverName string) (Server, error) {
-	file, err := GetFile(fileName)
-	if err != nil {
-		return Server{}, err
+	format := flags.String("format", "", "output format: text, table, json, jsonl, csv, tsv, html or sqlite (default table on a terminal, otherwise text)")
+	usipMode := flags.String("usip", netscaler.USIPModeYes, "services to output by usip value: yes, no, unset or all")
+	protocol := flags.String("protocol", "", "comma-separated protocols to output, such as SSL,HTTP (default all)")
+	state := flags.String("state", "", "services to output by state: enabled or disabled (default all)")
+	columnList := flags.String("columns", "", "comma-separated columns for table, csv and tsv output, such as name,ip,usip")
+	server := flags.String("server", "", "name of the server to output services of (default all)")
+	inconsistent := flags.Bool("inconsistent", false, "output every service of each server that has services both with and without USIP")
+	ipsOnly := flags.Bool("ips-only", false, "output only the sorted, unique IP addresses of the servers of services with USIP enabled")
+	nonstandardPorts := flags.Bool("nonstandard-ports", false, "output only services on ports other than 80 and 443")
+	cidr := flags.String("cidr", "", "network to output services from, such as 10.0.0.0/8 (default all)")
+	sortKey := flags.String("sort", netscaler.SortByName, "order of the output: name, ip, protocol or port")
+	exclude := flags.String("exclude", "", "regular expression; services whose name matches are not output")
+	encoding := flags.String("encoding", netscaler.EncodingUTF8, "encoding of the configuration files: utf-8 or latin1")
+	limit := flags.Int("limit", 0, "stop parsing after this many services, across all files (default 0, no limit)")
+	count := flags.Bool("count", false, "print only the number of services with USIP enabled, totalled across all files")
+	summary := flags.Bool("summary", false, "print a summary of the parsed services to stderr")
+	verbose := flags.Bool("v", false, "log parsing details to stderr")
+	showProgress := flags.Bool("progress", false, "report each file as it is parsed when stderr is a terminal")
+	quiet := flags.Bool("quiet", false, "write nothing to stderr but errors, overriding -v and -summary")
+	check := flags.Bool("check", false, "only report parse issues, exiting with a non-zero status if there are any")
+	diff := flags.Bool("diff", false, "compare two files and list the services that gained or lost USIP")
+	watchFile := flags.Bool("watch", false, "keep running, listing the services that gain or lose USIP whenever the file changes")
+	interval := flags.Duration("interval", 2*time.Second, "how often -watch checks the file for changes")
+	output := flags.String("o", "-", `output file, "-" for stdout or "auto" for <file>-usip-output.<format>`)
+	version := flags.Bool("version", false, "print the version and exit")
+	outputDir := flags.String("output-dir", "", "directory to write a separate <file>-usip-output.<format> to for each file")
+	list := flags.String("list", "", "file listing configuration files to read, one per line")
+	checkMode := flags.Bool("check-mode", false, "exit with 0 when no services use USIP, 1 when some do and 2 on a parse error")
+	err = flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
 	}
-	addServerLines, err := GetConfig(file, "(add server).*")
 	if err != nil {
-		return Server{}, err
+		return err
+	}
+	if *checkMode {
+		defer func() {
+			var exitErr *exitError
+			if err != nil && !errors.As(err, &exitErr) {
+				err = &exitError{code: exitParseError, err: err}
+			}
+		}()
+	}
+	// notices receives the warnings, summary and log output, none of which are needed when -quiet is given.
+	notices := io.Writer(os.Stderr)
+	if *quiet {
+		notices = io.Discard
+	}
+	if *verbose {
+		netscaler.SetLogOutput(notices)
+	}
+	args = flags.Args()
+	if *version {
+		fmt.Println(Version)
+		return nil
+	}
+	if *list != "" {
+		fileNames, err := readManifest(*list)
+		if err != nil {
+			return err
+		}
+		args = append(args, fileNames...)
 	}
-	for _, addServerLine := range addServerLines {
-		serverLine := RemoveConfigKeywords(addServerLine, "add server ")
-		quoteIndex, err := QuoteIndex(serverLine)
+	if len(args) == 0 && IsTerminal(os.Stdin) {
+		flags.Usage()
+		return errors.New("no configuration file given")
+	}
+	if !netscaler.KnownEncoding(*encoding) {
+		return fmt.Errorf("unknown -encoding value: %s", *encoding)
+	}
+	if *diff {
+		if len(args) != 2 {
+			return errors.New("-diff requires two file names: the old and the new configuration")
+		}
+		path, err := outputPath(*output, args, "text")
+		if err != nil {
+			return err
+		}
+		config := netscaler.ParserConfig{Encoding: *encoding}
+		added, removed, err := config.DiffUSIP(context.Background(), args[0], args[1])
 		if err != nil {
-			return Server{}, err
-		}
-		length := len(quoteIndex)
-		if length != 0 {
-			intSlice := quoteIndex[0][0]
-			if intSlice == 0 {
-				extractedQuote, err := ExtractQuote(serverLine)
-				if err != nil {
-					return Server{}, err
-				}
-				lineTrim := strings.TrimSpace(extractedQuote)
-				removedQuote := RemoveQuote(lineTrim)
-				if serverName == removedQuote {
-					var server Server
-					server.name = removedQuote
-					removeName := strings.Replace(serverLine, lineTrim, "", 1)
-					trimSpace := strings.TrimSpace(removeName)
-					server.ipAddress = trimSpace
-					return server, nil
-				}
+			return err
+		}
+		return writeOutput(path, func(w io.Writer, _ []netscaler.Service) error {
+			return netscaler.WriteDiff(w, added, removed)
+		}, nil)
+	}
+	if *check {
+		return checkFiles(args, netscaler.ParserConfig{Encoding: *encoding})
+	}
+	if *watchFile {
+		if len(args) != 1 {
+			return errors.New("-watch requires a single file name")
+		}
+		if *interval <= 0 {
+			return fmt.Errorf("invalid -interval: %s", *interval)
+		}
+		if _, err := os.Stat(args[0]); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return watch(ctx, netscaler.ParserConfig{Encoding: *encoding}, args[0], *interval, os.Stdout, os.Stderr)
+	}
+	if len(args) > 0 && args[0] == "dump" {
+		if len(args) != 2 {
+			return errors.New("dump requires a single file name")
+		}
+		config, err := netscaler.ParserConfig{Encoding: *encoding}.ParseAll(args[1])
+		if err != nil {
+			return err
+		}
+		return netscaler.WriteConfig(os.Stdout, *config)
+	}
+	var excludePattern *regexp.Regexp
+	if *exclude != "" {
+		var err error
+		excludePattern, err = regexp.Compile(*exclude)
+		if err != nil {
+			return fmt.Errorf("invalid -exclude pattern: %w", err)
+		}
+	}
+	switch strings.ToUpper(*state) {
+	case "", netscaler.StateEnabled, netscaler.StateDisabled:
+	default:
+		return fmt.Errorf("unknown -state value: %s", *state)
+	}
+	switch *usipMode {
+	case netscaler.USIPModeYes, netscaler.USIPModeNo, netscaler.USIPModeUnset, netscaler.USIPModeAll:
+	default:
+		return fmt.Errorf("unknown -usip value: %s", *usipMode)
+	}
+	if *format == "" {
+		*format = "text"
+		if *outputDir == "" && (*output == "-" || *output == "") && IsTerminal(os.Stdout) {
+			*format = "table"
+		}
+	}
+	if !netscaler.KnownFormat(*format) && *format != "sqlite" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	writer := func(w io.Writer, services []netscaler.Service) error {
+		return netscaler.WriteResults(w, services, *format)
+	}
+	if *columnList != "" {
+		columns, err := netscaler.SelectColumns(strings.Split(*columnList, ","))
+		if err != nil {
+			return err
+		}
+		switch *format {
+		case "table":
+			writer = func(w io.Writer, services []netscaler.Service) error {
+				return netscaler.WriteTableColumns(w, services, columns)
+			}
+		case "csv":
+			writer = func(w io.Writer, services []netscaler.Service) error {
+				return netscaler.WriteCSVColumns(w, services, columns)
 			}
-			if intSlice != 0 {
-				// There are instances where comments are added to the server configuration.  This block handles situations
-				// where comments are included.  Because comments are included, there are additional quotes surrounding
-				// the comments.
-				extractNoQuote, err := ExtractNoQuote(serverLine)
-				if err != nil {
-					return Server{}, err
-				}
-				lineTrim := strings.TrimSpace(extractNoQuote)
-				if serverName == lineTrim {
-					var server Server
-					server.name = lineTrim
-					removeName := strings.Replace(serverLine, extractNoQuote, "", 1)
-					trimSpace := strings.TrimSpace(removeName)
-					serverCommentLineArray := strings.Split(trimSpace, " ")
-					server.ipAddress = serverCommentLineArray[0]
-					return server, nil
-				}
+		case "tsv":
+			writer = func(w io.Writer, services []netscaler.Service) error {
+				return netscaler.WriteTSVColumns(w, services, columns)
 			}
+		default:
+			return fmt.Errorf("-columns applies only to table, csv and tsv output, not %s", *format)
+		}
+	}
+	if *ipsOnly {
+		if *format == "sqlite" || *columnList != "" {
+			return errors.New("-ips-only cannot be used with -format sqlite or -columns")
 		}
-		if length == 0 {
-			serverLineArray := strings.Split(serverLine, " ")
-			if serverName == serverLineArray[0] {
-				var server Server
-				server.name = serverLineArray[0]
-				server.ipAddress = strings.Replace(serverLineArray[1], "\r", "", -1)
-				return server, nil
+		writer = writeServerIPs
+	}
+	path, err := outputPath(*output, args, *format)
+	if err != nil {
+		return err
+	}
+	if *outputDir != "" {
+		if path != "-" {
+			return errors.New("-o and -output-dir cannot be used together")
+		}
+		if len(args) == 0 {
+			return errors.New("-output-dir requires file names")
+		}
+	}
+	if *format == "sqlite" && (*outputDir != "" || path == "" || path == "-") {
+		return errors.New("-format sqlite requires -o to name the database file")
+	}
+	var services []netscaler.Service
+	var parsed []string
+	var failed int
+	if len(args) == 0 {
+		stdin, err := netscaler.ReadConfig(os.Stdin)
+		if err != nil {
+			return err
+		}
+		stdin, err = netscaler.Decode(stdin, *encoding)
+		if err != nil {
+			return err
+		}
+		services, err = netscaler.ParserConfig{Limit: *limit}.ParseAllServices(context.Background(), stdin)
+		if err != nil {
+			return err
+		}
+	} else {
+		var progress func(int, int, string, int, error)
+		if *showProgress && !*quiet && IsTerminal(os.Stderr) {
+			progress = writeProgress
+		}
+		config := netscaler.ParserConfig{Limit: *limit, Encoding: *encoding}
+		services, parsed, failed, err = parseFiles(args, config, progress)
+		if err != nil {
+			return err
+		}
+	}
+	var protocols []string
+	if *protocol != "" {
+		for _, p := range strings.Split(*protocol, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	services = netscaler.FilterByNamePattern(netscaler.DedupeServices(services), excludePattern, true)
+	services = netscaler.FilterByState(services, *state)
+	services = netscaler.FilterByServer(services, *server)
+	if *nonstandardPorts {
+		services = netscaler.FilterByPortPredicate(services, netscaler.NonstandardPort)
+	}
+	if *cidr != "" {
+		services, err = netscaler.FilterByCIDR(services, *cidr)
+		if err != nil {
+			return fmt.Errorf("invalid -cidr network: %w", err)
+		}
+	}
+	if *inconsistent {
+		var mixed []netscaler.Service
+		for _, serverServices := range netscaler.FindInconsistentUSIP(services) {
+			mixed = append(mixed, serverServices...)
+		}
+		services = mixed
+		*usipMode = netscaler.USIPModeAll
+	}
+	for _, service := range services {
+		for _, warning := range service.Warnings {
+			fmt.Fprintf(notices, "warning: service %q: %s\n", service.Name, warning)
+		}
+	}
+	if *count {
+		// Only the number of services with USIP enabled is written, for use by monitoring scripts.
+		fmt.Println(netscaler.Summarize(netscaler.FilterByProtocol(services, protocols)).USIP)
+	} else {
+		usipServices := netscaler.FilterByProtocol(netscaler.FilterByUSIP(services, *usipMode), protocols)
+		err = netscaler.SortServices(usipServices, *sortKey)
+		if err != nil {
+			return err
+		}
+		if *ipsOnly {
+			warnDomainServers(notices, usipServices)
+		}
+		if *format == "sqlite" {
+			err = netscaler.WriteSQLite(path, usipServices)
+		} else if *outputDir != "" {
+			err = writeOutputDir(*outputDir, parsed, *format, writer, usipServices)
+		} else {
+			err = writeOutput(path, writer, usipServices)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if *summary {
+		summary := netscaler.Summarize(services)
+		// Summarize only sees the files that services were read from, so a file without any would not be counted.
+		summary.Files = len(parsed) + failed
+		if len(args) == 0 {
+			summary.Files = 1
+		}
+		fmt.Fprintln(notices, summary)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be parsed", failed, len(args))
+	}
+	if *checkMode {
+		if usip := netscaler.Summarize(netscaler.FilterByProtocol(services, protocols)).USIP; usip > 0 {
+			return &exitError{code: exitUSIPFound, err: fmt.Errorf("%d services with USIP enabled", usip)}
+		}
+	}
+	return nil
+}
+
+// parseFiles is a function that parses each of fileNames in turn and returns their combined services along with the
+// names of the files that were parsed and the number that could not be.  A file that cannot be parsed is reported to
+// stderr without stopping the others, unless it is the only file, in which case its error is returned.  Each file is
+// parsed with the options of config, and when its Limit is greater than zero parsing stops once that many services have
+// been read across all the files, leaving any remaining files unread.  When progress is not nil it is called once for
+// each file after it has been parsed, with the number of files done so far, the number of files, the file name and its
+// number of services or error.
+func parseFiles(fileNames []string, config netscaler.ParserConfig, progress func(done, total int, fileName string,
+	services int, err error)) ([]netscaler.Service, []string, int, error) {
+	var services []netscaler.Service
+	var parsed []string
+	var failed int
+	limit := config.Limit
+	for ix, fileName := range fileNames {
+		if limit > 0 && len(services) >= limit {
+			break
+		}
+		if limit > 0 {
+			config.Limit = limit - len(services)
+		}
+		fileServices, err := config.GetAllServices(context.Background(), fileName)
+		if progress != nil {
+			progress(ix+1, len(fileNames), fileName, len(fileServices), err)
+		}
+		if err != nil {
+			if len(fileNames) == 1 {
+				return nil, nil, 0, err
 			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", fileName, err)
+			failed++
+			continue
 		}
+		services = append(services, fileServices...)
+		parsed = append(parsed, fileName)
 	}
-	return Server{}, errors.New("no servers returned")
+	return services, parsed, failed, nil
 }
 
-// GetServices is a function that returns an array of Load Balancing services.  It accepts a filename
-// as a parameter.
-func GetServices(fileName string) ([]Service, error) {
-	file, err := GetFile(fileName)
-	if err != nil {
-		return []Service{}, err
+// writeServerIPs is a function that writes the IP addresses returned by netscaler.UniqueServerIPs for services to w,
+// one per line, for the -ips-only flag.
+func writeServerIPs(w io.Writer, services []netscaler.Service) error {
+	for _, ip := range netscaler.UniqueServerIPs(services) {
+		if _, err := fmt.Fprintln(w, ip); err != nil {
+			return err
+		}
 	}
-	addServiceLines, err := GetConfig(file, "(add service ).*")
+	return nil
+}
+
+// warnDomainServers is a function that writes a warning to w for each server of services with USIP enabled that is
+// defined by domain name, and so is left out of the -ips-only output.
+func warnDomainServers(w io.Writer, services []netscaler.Service) {
+	warned := make(map[string]bool)
+	for _, service := range services {
+		server := service.Server
+		if !service.HasUSIP() || server.IPAddress != "" || server.Domain == "" || warned[server.Name] {
+			continue
+		}
+		warned[server.Name] = true
+		fmt.Fprintf(w, "warning: server %q is defined by domain name %s and has no IP address to output\n", server.Name,
+			server.Domain)
+	}
+}
+
+// writeProgress is a function that writes a progress line for a file parsed by parseFiles to stderr, such as
+// "[3/12] parsing conf3 ... 412 services".
+func writeProgress(done, total int, fileName string, services int, err error) {
+	result := fmt.Sprintf("%d services", services)
 	if err != nil {
-		return []Service{}, err
+		result = "failed"
 	}
-	var services []Service
-	for _, addServiceLine := range addServiceLines {
-		serviceLine := RemoveConfigKeywords(addServiceLine, "add service ")
-		quoteIndex, err := QuoteIndex(serviceLine)
+	fmt.Fprintf(os.Stderr, "[%d/%d] parsing %s ... %s\n", done, total, fileName, result)
+}
+
+// checkFiles is a function that writes the issues found in each of the named files, or in stdin when there are none,
+// to stdout, reading them in the Encoding of config.  It returns an error when there are any issues so that the
+// program exits with a non-zero status.
+func checkFiles(args []string, config netscaler.ParserConfig) error {
+	var total int
+	check := func(name string, issues []netscaler.Issue, err error) {
 		if err != nil {
-			return nil, err
-		}
-		length := len(quoteIndex)
-		if length != 0 { // First quote for service name.
-			intSlice := quoteIndex[0][0]
-			if intSlice == 0 {
-				extractedQuote, err := ExtractQuote(serviceLine)
-				if err != nil {
-					return nil, err
-				}
-				trimLine := strings.TrimSpace(extractedQuote)
-				removedQuote := RemoveQuote(trimLine)
-				var service Service
-				service.name = removedQuote
-				replaceName := strings.Replace(serviceLine, trimLine, "", 1)
-				trimSpace := strings.TrimSpace(replaceName)
-				quoteIndex, err := QuoteIndex(trimSpace)
-				if err != nil {
-					return nil, err
-				}
-				length := len(quoteIndex)
-				if length != 0 { // Second quote for server name
-					intSlice := quoteIndex[0][0]
-					if intSlice == 0 {
-						extractedQuote, err := ExtractQuote(trimSpace)
-						if err != nil {
-							return nil, err
-						}
-						trimLine := strings.TrimSpace(extractedQuote)
-						removedQuote := RemoveQuote(trimLine)
-						serviceServer, err := BuildServer(fileName, removedQuote)
-						if err != nil {
-							return nil, err
-						}
-						service.server = serviceServer
-						replaceName := strings.Replace(trimSpace, extractedQuote, "", 1)
-						trimSpace := strings.TrimSpace(replaceName)
-						serviceLineArray := strings.Split(trimSpace, " ")
-						service.protocol = serviceLineArray[0]
-						service.port = serviceLineArray[1]
-						for ix, arr := range serviceLineArray {
-							if arr == "-usip" {
-								service.usip = serviceLineArray[ix+1]
-							}
-						}
-						services = append(services, service)
-					}
-					if intSlice != 0 {
-						// As of now, this situation does not need to be handled.  There should not be any other
-						// quotes.  If that changes, the code would go here.
-					}
-				}
-				if length == 0 { // No quote for server name
-					serviceLineArray := strings.Split(trimSpace, " ")
-					service.server, err = BuildServer(fileName, serviceLineArray[0])
-					if err != nil {
-						return nil, err
-					}
-					service.protocol = serviceLineArray[1]
-					service.port = serviceLineArray[2]
-					for ix, arr := range serviceLineArray {
-						if arr == "-usip" {
-							service.usip = serviceLineArray[ix+1]
-						}
-					}
-					services = append(services, service)
-				}
-			}
-			if intSlice != 0 { // No quote for service name.
-				extractNoQuote, err := ExtractNoQuote(serviceLine)
-				if err != nil {
-					return nil, err
-				}
-				trimNoQuote := strings.TrimSpace(extractNoQuote)
-				var service Service
-				service.name = trimNoQuote
-				replaceNoQuote := strings.Replace(serviceLine, extractNoQuote, "", 1)
-				trimReplace := strings.TrimSpace(replaceNoQuote)
-				quoteIndex, err := QuoteIndex(trimReplace)
-				if err != nil {
-					return nil, err
-				}
-				length := len(quoteIndex)
-				if length != 0 { // Quote for server name of service name with no quote.
-					intSlice := quoteIndex[0][0]
-					if intSlice == 0 {
-						extractQuote, err := ExtractQuote(trimReplace)
-						if err != nil {
-							return nil, err
-						}
-						trimQuote := strings.TrimSpace(extractQuote)
-						removeQuote := RemoveQuote(trimQuote)
-						service.server, err = BuildServer(fileName, removeQuote)
-						if err != nil {
-							return nil, err
-						}
-						replaceQuote := strings.Replace(trimReplace, extractQuote, "", 1)
-						trimQuote = strings.TrimSpace(replaceQuote)
-						serviceLineArray := strings.Split(trimQuote, " ")
-						service.protocol = serviceLineArray[0]
-						service.port = serviceLineArray[1]
-						for ix, arr := range serviceLineArray {
-							if arr == "-usip" {
-								service.usip = serviceLineArray[ix+1]
-							}
-						}
-					}
-				}
-				services = append(services, service)
-			}
+			issues = append(issues, netscaler.Issue{Message: err.Error()})
 		}
-		if length == 0 {
-			// This section is for no quotes detected.
-			trimSpace := strings.TrimSpace(serviceLine)
-			serviceLineArray := strings.Split(trimSpace, " ")
-			var service Service
-			service.name = serviceLineArray[0]
-			service.server, err = BuildServer(fileName, serviceLineArray[1])
-			if err != nil {
-				return nil, err
-			}
-			service.protocol = serviceLineArray[2]
-			service.port = serviceLineArray[3]
-			for ix, arr := range serviceLineArray {
-				if arr == "-usip" {
-					service.usip = serviceLineArray[ix+1]
-				}
-			}
-			services = append(services, service)
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", name, issue)
+		}
+		total += len(issues)
+	}
+	if len(args) == 0 {
+		stdin, err := netscaler.ReadConfig(os.Stdin)
+		if err != nil {
+			return err
+		}
+		stdin, err = netscaler.Decode(stdin, config.Encoding)
+		if err != nil {
+			return err
 		}
+		issues, err := netscaler.ValidateConfig(stdin)
+		check("stdin", issues, err)
+	}
+	for _, filename := range args {
+		issues, err := config.Validate(filename)
+		check(filename, issues, err)
+	}
+	if total > 0 {
+		return fmt.Errorf("%d issues found", total)
 	}
-	return services, nil
+	return nil
 }
 
-// CreateFile is a function that accepts a file name as a parameter and returns a pointer to a file.
-func CreateFile(fileName string) (*os.File, error) {
-	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// readManifest is a function that returns the file names listed in the manifest at path, one per line.  Blank lines
+// and lines starting with # are skipped, and relative paths are resolved against the directory of the manifest so that
+// it can be used from anywhere.
+func readManifest(path string) ([]string, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	defer file.Close()
+	var fileNames []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !netscaler.IsURL(line) && !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		fileNames = append(fileNames, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fileNames, nil
 }
 
-// main contains the business logic of the program.  It returns a file with the Load Balancing service name, server
-// name and server IP address of services that are using usip (use source IP address).
-func main() {
-	filename := os.Args[1]
-	services, err := GetServices(filename)
+// outputPath is a function that returns the path the output is written to for the -o flag value.  The value "auto"
+// names the output after the single input file, as in <file>-usip-output.txt, and an empty path or "-" means stdout.
+func outputPath(output string, args []string, format string) (string, error) {
+	if output != "auto" {
+		return output, nil
+	}
+	switch len(args) {
+	case 0:
+		return "-", nil
+	case 1:
+		if netscaler.IsURL(args[0]) {
+			return "", errors.New(`-o auto requires a file rather than a URL`)
+		}
+		return args[0] + "-usip-output." + outputExtension(format), nil
+	default:
+		return "", errors.New(`-o auto requires a single input file`)
+	}
+}
+
+// outputExtension is a function that returns the file name extension of the output format, such as txt for text.
+func outputExtension(format string) string {
+	if format == "text" {
+		return "txt"
+	}
+	return format
+}
+
+// writeOutputDir is a function that writes the services of each of fileNames with writer to its own file within dir,
+// named <basename>-usip-output.<ext> after the file.  The directory is created if it does not exist.  When two files
+// share a base name, as in a/ns.conf and b/ns.conf, the later ones are numbered, as in ns.conf-2-usip-output.txt.
+func writeOutputDir(dir string, fileNames []string, format string, writer func(io.Writer, []netscaler.Service) error,
+	services []netscaler.Service) error {
+	err := os.MkdirAll(dir, 0755)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	for _, service := range services {
-		if service.usip == "YES" {
-			file, err := CreateFile(filename + "-usip-output.txt")
-			if err != nil {
-				fmt.Println(err)
+	used := make(map[string]int)
+	for _, fileName := range fileNames {
+		base := filepath.Base(fileName)
+		used[base]++
+		if used[base] > 1 {
+			base = fmt.Sprintf("%s-%d", base, used[base])
+		}
+		var fileServices []netscaler.Service
+		for _, service := range services {
+			if service.SourceFile == fileName {
+				fileServices = append(fileServices, service)
 			}
-			fmt.Fprintln(file, service.name+" "+service.server.name+" "+service.server.ipAddress)
 		}
+		path := filepath.Join(dir, base+"-usip-output."+outputExtension(format))
+		err = writeOutput(path, writer, fileServices)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOutput is a function that writes services with writer to the file at path, replacing any previous contents,
+// or to stdout when path is empty or "-".
+func writeOutput(path string, writer func(io.Writer, []netscaler.Service) error, services []netscaler.Service) error {
+	if path == "" || path == "-" {
+		return writer(os.Stdout, services)
+	}
+	file, err := CreateFile(path)
+	if err != nil {
+		return err
+	}
+	err = writer(file, services)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// IsTerminal is a function that reports whether file is a terminal rather than a regular file or a pipe.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"usipProject/netscaler"
+)
+
+// fixture is a function that returns the path of the named file in the testdata of the netscaler package.
+func fixture(name string) string {
+	return filepath.Join("netscaler", "testdata", name)
+}
+
+// readFile is a function that returns the contents of the file at path, failing the test on an error.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+// captureOutput is a function that calls f with os.Stdout and os.Stderr redirected, and returns what it wrote to each
+// along with its error.
+func captureOutput(t *testing.T, f func() error) (stdout, stderr string, err error) {
+	t.Helper()
+	capture := func(file **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := *file
+		*file = w
+		done := make(chan string)
+		go func() {
+			contents, _ := io.ReadAll(r)
+			done <- string(contents)
+		}()
+		return func() string {
+			w.Close()
+			*file = original
+			return <-done
+		}
+	}
+	restoreStdout := capture(&os.Stdout)
+	restoreStderr := capture(&os.Stderr)
+	err = f()
+	return restoreStdout(), restoreStderr(), err
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	for ix := 0; ix < 2; ix++ {
+		if err := run([]string{"-o", output, "-usip", "all", fixture("servers_services.conf")}); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+	}
+	got := readFile(t, output)
+	for _, name := range []string{"svc1", "svc2", "svc3"} {
+		if count := strings.Count(got, name+" "); count != 1 {
+			t.Errorf("output has %q %d times, want once:\n%s", name, count, got)
+		}
+	}
+}
+
+func TestRunUSIPFlag(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, "-usip", "no", fixture("servers_services.conf")}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if got, want := readFile(t, output), "svc2 web1 10.0.0.1\n"; got != want {
+		t.Errorf("run(-usip no) wrote %q, want %q", got, want)
+	}
+	if err := run([]string{"-o", output, "-usip", "maybe", fixture("servers_services.conf")}); err == nil {
+		t.Error("run(-usip maybe) error = nil, want an error")
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	stdout, _, err := captureOutput(t, func() error {
+		return run([]string{"-count", fixture("servers_services.conf"), fixture("diff_after.conf")})
+	})
+	if err != nil {
+		t.Fatalf("run(-count) error = %v", err)
+	}
+	if want := "4\n"; stdout != want {
+		t.Errorf("run(-count) wrote %q, want %q", stdout, want)
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	_, stderr, err := captureOutput(t, func() error {
+		return run([]string{"-summary", fixture("servers_services.conf"), fixture("empty.conf")})
+	})
+	if err != nil {
+		t.Fatalf("run(-summary) error = %v", err)
+	}
+	if want := "parsed 3 services, 1 with USIP enabled, 2 files\n"; stderr != want {
+		t.Errorf("run(-summary) wrote %q to stderr, want %q", stderr, want)
+	}
+}
+
+func TestRunQuiet(t *testing.T) {
+	// -v sends the parsing details to the same place as the other notices, which stays in effect after run returns.
+	t.Cleanup(func() { netscaler.SetLogOutput(io.Discard) })
+	args := []string{"-summary", "-v", fixture("limits.conf")}
+	_, stderr, err := captureOutput(t, func() error { return run(args) })
+	if err != nil || !strings.Contains(stderr, "warning:") {
+		t.Fatalf("run(%q) wrote %q to stderr, %v, want warnings", args, stderr, err)
+	}
+	args = append([]string{"-quiet"}, args...)
+	stdout, stderr, err := captureOutput(t, func() error { return run(args) })
+	if err != nil {
+		t.Fatalf("run(%q) error = %v", args, err)
+	}
+	if stderr != "" {
+		t.Errorf("run(%q) wrote %q to stderr, want nothing", args, stderr)
+	}
+	if stdout == "" {
+		t.Errorf("run(%q) wrote nothing to stdout, want the services", args)
+	}
+}
+
+func TestRunExclude(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, "-usip", "all", "-exclude", "^svc[12]$", fixture("servers_services.conf")}); err != nil {
+		t.Fatalf("run(-exclude) error = %v", err)
+	}
+	if got, want := readFile(t, output), "svc3 web2 10.0.0.2\n"; got != want {
+		t.Errorf("run(-exclude) wrote %q, want %q", got, want)
+	}
+	err := run([]string{"-o", output, "-exclude", "svc[", fixture("servers_services.conf")})
+	if err == nil || !strings.Contains(err.Error(), "invalid -exclude pattern") {
+		t.Errorf("run(-exclude svc[) error = %v, want an invalid -exclude pattern error", err)
+	}
+}
+
+func TestRunStateFlag(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, "-state", "disabled", fixture("states.conf")}); err != nil {
+		t.Fatalf("run(-state disabled) error = %v", err)
+	}
+	if got, want := readFile(t, output), "disabled web1 10.0.0.1\n"; got != want {
+		t.Errorf("run(-state disabled) wrote %q, want %q", got, want)
+	}
+}
+
+func TestRunCommentsOnly(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, fixture("comments_only.conf")}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if got := readFile(t, output); got != "" {
+		t.Errorf("run() wrote %q, want nothing", got)
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	stdout, _, err := captureOutput(t, func() error {
+		return run([]string{"-check", "-o", output, fixture("issues.conf")})
+	})
+	if err == nil || !strings.Contains(err.Error(), "6 issues found") {
+		t.Errorf("run(-check) error = %v, want 6 issues found", err)
+	}
+	if !strings.Contains(stdout, "line 5: malformed add service") {
+		t.Errorf("run(-check) wrote %q, want the issues", stdout)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("run(-check) created the output file, stat error = %v", err)
+	}
+	if _, _, err := captureOutput(t, func() error {
+		return run([]string{"-check", fixture("servers_services.conf")})
+	}); err != nil {
+		t.Errorf("run(-check) of a valid file error = %v", err)
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	inputs := map[string]string{
+		filepath.Join(dir, "a", "ns.conf"): fixture("servers_services.conf"),
+		filepath.Join(dir, "b", "ns.conf"): fixture("states.conf"),
+	}
+	for path, source := range inputs {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(readFile(t, source)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outputDir := filepath.Join(dir, "out", "usip")
+	err := run([]string{"-output-dir", outputDir, filepath.Join(dir, "a", "ns.conf"), filepath.Join(dir, "b", "ns.conf")})
+	if err != nil {
+		t.Fatalf("run(-output-dir) error = %v", err)
+	}
+	want := map[string]string{
+		"ns.conf-usip-output.txt":   "svc1 web1 10.0.0.1\n",
+		"ns.conf-2-usip-output.txt": "disabled web1 10.0.0.1\nenabled web1 10.0.0.1\nunspecified web1 10.0.0.1\n",
+	}
+	for name, contents := range want {
+		if got := readFile(t, filepath.Join(outputDir, name)); got != contents {
+			t.Errorf("run(-output-dir) wrote %q to %s, want %q", got, name, contents)
+		}
+	}
+	if err := run([]string{"-output-dir", outputDir, "-o", "out.txt", fixture("states.conf")}); err == nil {
+		t.Error("run(-output-dir, -o) error = nil, want an error")
+	}
+}
+
+func TestRunNoArguments(t *testing.T) {
+	// The null device is a character device, so it stands in for a terminal on stdin.
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if !IsTerminal(null) {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+	stdin := os.Stdin
+	os.Stdin = null
+	defer func() { os.Stdin = stdin }()
+	_, stderr, err := captureOutput(t, func() error { return run(nil) })
+	if err == nil || !strings.Contains(err.Error(), "no configuration file given") {
+		t.Errorf("run() error = %v, want no configuration file given", err)
+	}
+	if !strings.HasPrefix(stderr, "usage: usip") {
+		t.Errorf("run() wrote %q to stderr, want the usage", stderr)
+	}
+	_, stderr, err = captureOutput(t, func() error { return run([]string{"-h"}) })
+	if err != nil || !strings.Contains(stderr, "-check-mode") {
+		t.Errorf("run(-h) = %v, stderr %q, want the usage and no error", err, stderr)
+	}
+}
+
+func TestRunServerFlag(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, "-usip", "all", "-server", "web1", fixture("servers_services.conf")}); err != nil {
+		t.Fatalf("run(-server web1) error = %v", err)
+	}
+	if got, want := readFile(t, output), "svc1 web1 10.0.0.1\nsvc2 web1 10.0.0.1\n"; got != want {
+		t.Errorf("run(-server web1) wrote %q, want %q", got, want)
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     int
+	}{
+		{fileName: "comments_only.conf", want: exitNoUSIP},
+		{fileName: "servers_services.conf", want: exitUSIPFound},
+		{fileName: "truncated_service.conf", want: exitParseError},
+		{fileName: "nonexistent.conf", want: exitParseError},
+	}
+	for _, tt := range tests {
+		output := filepath.Join(t.TempDir(), "out.txt")
+		err := run([]string{"-check-mode", "-o", output, fixture(tt.fileName)})
+		code := exitNoUSIP
+		if err != nil {
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) {
+				t.Errorf("run(-check-mode %s) error = %v, want an exitError", tt.fileName, err)
+				continue
+			}
+			code = exitErr.code
+		}
+		if code != tt.want {
+			t.Errorf("run(-check-mode %s) exit status = %d (%v), want %d", tt.fileName, code, err, tt.want)
+		}
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "configs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	relative := filepath.Join(dir, "configs", "ns.conf")
+	if err := os.WriteFile(relative, []byte(readFile(t, fixture("servers_services.conf"))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	absolute, err := filepath.Abs(fixture("diff_after.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "manifest.txt")
+	contents := "# production\nconfigs/ns.conf\n\n  " + absolute + "  \nhttps://configs.example.com/ns.conf\n"
+	if err := os.WriteFile(manifest, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileNames, err := readManifest(manifest)
+	if err != nil {
+		t.Fatalf("readManifest() error = %v", err)
+	}
+	want := []string{relative, absolute, "https://configs.example.com/ns.conf"}
+	if !reflect.DeepEqual(fileNames, want) {
+		t.Errorf("readManifest() = %q, want %q", fileNames, want)
+	}
+	// configs/ns.conf does not exist in the working directory, so it is only found relative to the manifest.
+	if err := os.WriteFile(manifest, []byte("configs/ns.conf\n"+absolute+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err := captureOutput(t, func() error { return run([]string{"-count", "-list", manifest}) })
+	if err != nil || stdout != "4\n" {
+		t.Errorf("run(-count -list) = %q, %v, want 4", stdout, err)
+	}
+}
+
+func TestParseFilesProgress(t *testing.T) {
+	fileNames := []string{fixture("servers_services.conf"), fixture("nonexistent.conf"), fixture("states.conf")}
+	var calls []string
+	progress := func(done, total int, fileName string, services int, err error) {
+		calls = append(calls, fmt.Sprintf("%d/%d %s %d %v", done, total, filepath.Base(fileName), services, err != nil))
+	}
+	_, stderr, err := captureOutput(t, func() error {
+		_, parsed, failed, err := parseFiles(fileNames, netscaler.ParserConfig{}, progress)
+		if len(parsed) != 2 || failed != 1 {
+			t.Errorf("parseFiles() parsed %v and failed %d, want 2 parsed and 1 failed", parsed, failed)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("parseFiles() error = %v", err)
+	}
+	want := []string{
+		"1/3 servers_services.conf 3 false",
+		"2/3 nonexistent.conf 0 true",
+		"3/3 states.conf 3 false",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("progress called with %q, want %q", calls, want)
+	}
+	if !strings.Contains(stderr, "nonexistent.conf") {
+		t.Errorf("parseFiles() wrote %q to stderr, want the failed file", stderr)
+	}
+	calls = nil
+	if _, _, _, err := parseFiles(fileNames, netscaler.ParserConfig{Limit: 2}, progress); err != nil {
+		t.Fatalf("parseFiles(Limit 2) error = %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("progress called with %q once the limit was reached, want a single call", calls)
+	}
+}
+
+func TestRunInconsistent(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := run([]string{"-o", output, "-inconsistent", fixture("inconsistent.conf")}); err != nil {
+		t.Fatalf("run(-inconsistent) error = %v", err)
+	}
+	want := "svc1 web1 10.0.0.1\nsvc2 web1 10.0.0.1\nsvc3 web1 10.0.0.1\n"
+	if got := readFile(t, output); got != want {
+		t.Errorf("run(-inconsistent) wrote %q, want %q", got, want)
+	}
+}
+
+func TestRunLimit(t *testing.T) {
+	stdout, _, err := captureOutput(t, func() error {
+		return run([]string{"-usip", "all", "-limit", "4", fixture("servers_services.conf"), fixture("states.conf")})
+	})
+	if err != nil {
+		t.Fatalf("run(-limit 4) error = %v", err)
+	}
+	// The output is sorted by name, so the one service read from states.conf comes first.
+	want := "enabled web1 10.0.0.1\nsvc1 web1 10.0.0.1\nsvc2 web1 10.0.0.1\nsvc3 web2 10.0.0.2\n"
+	if stdout != want {
+		t.Errorf("run(-limit 4) wrote %q, want %q", stdout, want)
+	}
+}
+
+func TestRunIPsOnly(t *testing.T) {
+	stdout, stderr, err := captureOutput(t, func() error {
+		return run([]string{"-ips-only", fixture("server_ips.conf")})
+	})
+	if err != nil {
+		t.Fatalf("run(-ips-only) error = %v", err)
+	}
+	if want := "10.0.0.9\n10.0.0.10\n2001:db8::1\n"; stdout != want {
+		t.Errorf("run(-ips-only) wrote %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, `server "web5" is defined by domain name web.example.com`) {
+		t.Errorf("run(-ips-only) wrote %q to stderr, want a warning for web5", stderr)
+	}
+	if err := run([]string{"-ips-only", "-columns", "name", fixture("server_ips.conf")}); err == nil {
+		t.Error("run(-ips-only -columns) error = nil, want an error")
+	}
+}
+
+func TestRunEncoding(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{fixture("latin1.conf")}, want: []string{"réseau café 10.0.0.1\n"}},
+		{args: []string{"-check", fixture("latin1.conf")}, want: nil},
+		{args: []string{"dump", fixture("latin1.conf")}, want: []string{`"name": "réseau"`, `"comment": "niño"`}},
+		{
+			args: []string{"-diff", fixture("latin1.conf"), fixture("latin1_after.conf")},
+			want: []string{"Added USIP services (1):\ndépôt café", "Removed USIP services (1):\nréseau café"},
+		},
+	}
+	for _, tt := range tests {
+		args := append([]string{"-encoding", netscaler.EncodingLatin1}, tt.args...)
+		stdout, _, err := captureOutput(t, func() error { return run(args) })
+		if err != nil {
+			t.Errorf("run(%q) error = %v", args, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("run(%q) wrote %q, want it to contain %q", args, stdout, want)
+			}
+		}
+	}
+	if err := run([]string{"-encoding", "utf-16", "-check", fixture("latin1.conf")}); err == nil {
+		t.Error("run(-encoding utf-16) error = nil, want an unknown encoding error")
+	}
+}
@@ -0,0 +1,148 @@
+package netscaler
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Column is a data structure for a column of the table and CSV output: its name and how its value is read from a
+// service.
+type Column struct {
+	Name  string
+	Value func(Service) string
+}
+
+// DefaultColumns are the names of the columns written by WriteTable and WriteCSV, in order.
+var DefaultColumns = []string{"name", "server", "ip", "protocol", "port", "usip"}
+
+// columnValues holds the accessor of each column that can be selected with SelectColumns, keyed by column name.
+var columnValues = map[string]func(Service) string{
+	"name":       func(s Service) string { return s.Name },
+	"server":     func(s Service) string { return s.Server.Name },
+	"ip":         func(s Service) string { return s.Server.Address() },
+	"protocol":   func(s Service) string { return s.Protocol },
+	"port":       func(s Service) string { return FormatPort(s.Port) },
+	"usip":       func(s Service) string { return s.USIP },
+	"state":      func(s Service) string { return s.State },
+	"comment":    func(s Service) string { return s.Server.Comment },
+	"cltTimeout": func(s Service) string { return formatLimit(s.CltTimeout) },
+	"svrTimeout": func(s Service) string { return formatLimit(s.SvrTimeout) },
+	"healthMonitor": func(s Service) string {
+		if s.HealthMonitor {
+			return "YES"
+		}
+		return "NO"
+	},
+}
+
+// SelectColumns is a function that returns the columns with the given names, in the order given.  Names are matched
+// ignoring case and surrounding whitespace, and an unknown name is an error.  When names is empty the DefaultColumns
+// are returned.
+func SelectColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		column, ok := lookupColumn(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown column: %s", strings.TrimSpace(name))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// lookupColumn is a function that returns the column whose name matches name ignoring case.
+func lookupColumn(name string) (Column, bool) {
+	for columnName, value := range columnValues {
+		if strings.EqualFold(columnName, name) {
+			return Column{Name: columnName, Value: value}, true
+		}
+	}
+	return Column{}, false
+}
+
+// formatLimit is a function that returns limit as a string, or an empty string when it is not set.
+func formatLimit(limit int) string {
+	if limit == 0 {
+		return ""
+	}
+	return strconv.Itoa(limit)
+}
+
+// WriteTableColumns is a function that writes services to w as a table of the given columns, with a header row of the
+// upper-case column names and aligned columns.
+func WriteTableColumns(w io.Writer, services []Service, columns []Column) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for ix, column := range columns {
+		header[ix] = strings.ToUpper(column.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, service := range services {
+		fields := make([]string, len(columns))
+		for ix, column := range columns {
+			fields[ix] = column.Value(service)
+		}
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteCSVColumns is a function that writes services to w as CSV of the given columns with a header row of the column
+// names.  The header is written even when there are no services.
+func WriteCSVColumns(w io.Writer, services []Service, columns []Column) error {
+	header := make([]string, len(columns))
+	for ix, column := range columns {
+		header[ix] = column.Name
+	}
+	_, err := fmt.Fprintln(w, strings.Join(header, ","))
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		fields := make([]string, len(columns))
+		for ix, column := range columns {
+			fields[ix] = csvField(column.Value(service))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(fields, ","))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTSVColumns is a function that writes services to w as tab-separated values of the given columns with a header
+// row of the column names.  Fields are not quoted; instead any tabs or line breaks within a field are removed so that
+// they cannot be mistaken for separators.
+func WriteTSVColumns(w io.Writer, services []Service, columns []Column) error {
+	header := make([]string, len(columns))
+	for ix, column := range columns {
+		header[ix] = column.Name
+	}
+	_, err := fmt.Fprintln(w, strings.Join(header, "\t"))
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		fields := make([]string, len(columns))
+		for ix, column := range columns {
+			fields[ix] = tsvField(column.Value(service))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(fields, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tsvField is a function that removes the tabs and line breaks from a TSV field.
+func tsvField(field string) string {
+	return strings.NewReplacer("\t", "", "\r", "", "\n", "").Replace(field)
+}
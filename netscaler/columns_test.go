@@ -0,0 +1,56 @@
+package netscaler
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// columnNames is a function that returns the names of columns, in order.
+func columnNames(columns []Column) []string {
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func TestSelectColumns(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{names: nil, want: DefaultColumns},
+		{names: []string{"name", "ip", "usip"}, want: []string{"name", "ip", "usip"}},
+		{names: []string{"usip", "name"}, want: []string{"usip", "name"}},
+		{names: []string{" IP ", "CltTimeout"}, want: []string{"ip", "cltTimeout"}},
+	}
+	for _, tt := range tests {
+		columns, err := SelectColumns(tt.names)
+		if err != nil {
+			t.Errorf("SelectColumns(%q) error = %v", tt.names, err)
+			continue
+		}
+		if got := columnNames(columns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SelectColumns(%q) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+	if _, err := SelectColumns([]string{"name", "color"}); err == nil {
+		t.Error("SelectColumns(name, color) error = nil, want an unknown column error")
+	}
+}
+
+func TestWriteCSVColumns(t *testing.T) {
+	columns, err := SelectColumns([]string{"usip", "name", "ip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w bytes.Buffer
+	if err := WriteCSVColumns(&w, outputServices(), columns); err != nil {
+		t.Fatalf("WriteCSVColumns() error = %v", err)
+	}
+	want := "usip,name,ip\nYES,svc1,10.0.0.1\n,\"long service\",2001:db8::1\n"
+	if got := w.String(); got != want {
+		t.Errorf("WriteCSVColumns() wrote %q, want %q", got, want)
+	}
+}
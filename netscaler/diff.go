@@ -0,0 +1,77 @@
+package netscaler
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// DiffUSIP is a function that compares the services of two versions of a NetScaler configuration.  added holds the
+// services with USIP enabled in newFile that were not enabled in oldFile, and removed holds the reverse.  Services
+// are matched by name, server and port.
+func DiffUSIP(oldFile, newFile string) (added, removed []Service, err error) {
+	return ParserConfig{}.DiffUSIP(context.Background(), oldFile, newFile)
+}
+
+// DiffUSIP is a method that compares the services of two versions of a NetScaler configuration, parsed with the options
+// of the ParserConfig, as the DiffUSIP function does.
+func (c ParserConfig) DiffUSIP(ctx context.Context, oldFile, newFile string) (added, removed []Service, err error) {
+	oldServices, err := c.GetAllServices(ctx, oldFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	newServices, err := c.GetAllServices(ctx, newFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	added, removed = DiffServices(oldServices, newServices)
+	return added, removed, nil
+}
+
+// DiffServices is a function that compares two sets of services as DiffUSIP does for two files.  added holds the
+// services with USIP enabled in newServices that were not enabled in oldServices, and removed holds the reverse.
+func DiffServices(oldServices, newServices []Service) (added, removed []Service) {
+	return diffUSIP(oldServices, newServices), diffUSIP(newServices, oldServices)
+}
+
+// diffUSIP is a function that returns the services with USIP enabled in to that do not have it enabled in from.
+func diffUSIP(from, to []Service) []Service {
+	enabled := make(map[serviceKey]bool)
+	for _, service := range from {
+		if service.HasUSIP() {
+			enabled[serviceIdentity(service)] = true
+		}
+	}
+	var changed []Service
+	for _, service := range to {
+		if service.HasUSIP() && !enabled[serviceIdentity(service)] {
+			changed = append(changed, service)
+		}
+	}
+	return changed
+}
+
+// WriteDiff is a function that writes the results of DiffUSIP to w as two labelled lists in the plain text format.
+func WriteDiff(w io.Writer, added, removed []Service) error {
+	sections := []struct {
+		label    string
+		services []Service
+	}{
+		{"Added USIP services", added},
+		{"Removed USIP services", removed},
+	}
+	for ix, section := range sections {
+		if ix > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s (%d):\n", section.label, len(section.services)); err != nil {
+			return err
+		}
+		if err := WriteText(w, section.services); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package netscaler
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffUSIP(t *testing.T) {
+	added, removed, err := DiffUSIP(fixture("diff_before.conf"), fixture("diff_after.conf"))
+	if err != nil {
+		t.Fatalf("DiffUSIP() error = %v", err)
+	}
+	if got, want := serviceNames(added), []string{"svc2", "svc4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffUSIP() added = %v, want %v", got, want)
+	}
+	if got, want := serviceNames(removed), []string{"svc3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffUSIP() removed = %v, want %v", got, want)
+	}
+	var w bytes.Buffer
+	if err := WriteDiff(&w, added, removed); err != nil {
+		t.Fatalf("WriteDiff() error = %v", err)
+	}
+	want := "Added USIP services (2):\nsvc2 web1 10.0.0.1\nsvc4 web1 10.0.0.1\n\n" +
+		"Removed USIP services (1):\nsvc3 web1 10.0.0.1\n"
+	if w.String() != want {
+		t.Errorf("WriteDiff() wrote %q, want %q", w.String(), want)
+	}
+}
@@ -0,0 +1,54 @@
+package netscaler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The encodings that a configuration can be decoded from.  Names are matched without regard to case, and
+// "iso-8859-1" is accepted for EncodingLatin1.
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingLatin1 = "latin1"
+)
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which some editors on Windows write at the start of a file.
+const byteOrderMark = "\ufeff"
+
+// RemoveBOM is a function that removes the UTF-8 byte order mark from the start of the contents of a file, if there is
+// one, so that the first line parses like any other.
+func RemoveBOM(file string) string {
+	return strings.TrimPrefix(file, byteOrderMark)
+}
+
+// KnownEncoding is a function that reports whether encoding is one of the encodings accepted by Decode.
+func KnownEncoding(encoding string) bool {
+	switch strings.ToLower(encoding) {
+	case "", EncodingUTF8, "utf8", EncodingLatin1, "iso-8859-1":
+		return true
+	}
+	return false
+}
+
+// Decode is a function that returns the contents of a file read in the named encoding as UTF-8.  UTF-8 contents, the
+// default when encoding is empty, are returned unchanged; Latin-1 contents have each byte converted to the character
+// it stands for, so that names with accented letters are not garbled.  An unknown encoding is an error.
+func Decode(file, encoding string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", EncodingUTF8, "utf8":
+		return file, nil
+	case EncodingLatin1, "iso-8859-1":
+		var decoded strings.Builder
+		decoded.Grow(len(file))
+		for ix := 0; ix < len(file); ix++ {
+			if file[ix] < utf8.RuneSelf {
+				decoded.WriteByte(file[ix])
+			} else {
+				decoded.WriteRune(rune(file[ix]))
+			}
+		}
+		return decoded.String(), nil
+	}
+	return "", fmt.Errorf("unknown encoding: %s", encoding)
+}
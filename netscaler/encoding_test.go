@@ -0,0 +1,88 @@
+package netscaler
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file     string
+		encoding string
+		want     string
+	}{
+		{file: "caf\xe9", encoding: EncodingLatin1, want: "café"},
+		{file: "ni\xf1o", encoding: "ISO-8859-1", want: "niño"},
+		{file: "café", encoding: EncodingUTF8, want: "café"},
+		{file: "café", encoding: "", want: "café"},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.file, tt.encoding)
+		if err != nil || got != tt.want {
+			t.Errorf("Decode(%q, %q) = %q, %v, want %q", tt.file, tt.encoding, got, err, tt.want)
+		}
+	}
+	if _, err := Decode("café", "utf-16"); err == nil {
+		t.Error(`Decode("utf-16") error = nil, want an unknown encoding error`)
+	}
+	if KnownEncoding("utf-16") || !KnownEncoding("LATIN1") {
+		t.Error("KnownEncoding() does not match the encodings Decode accepts")
+	}
+}
+
+func TestGetServicesBOM(t *testing.T) {
+	services := servicesByName(t, "bom.conf")
+	if service, ok := services["svc1"]; !ok || service.Server.IPAddress != "10.0.0.1" || service.LineNumber != 2 {
+		t.Errorf("GetServices() = %+v, want svc1 on 10.0.0.1 from line 2", services)
+	}
+	file, err := os.Open(fixture("bom.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanned, err := ScanServices(file)
+	if err != nil {
+		t.Fatalf("ScanServices() error = %v", err)
+	}
+	if want := getServices(t, "bom.conf"); !reflect.DeepEqual(scanned, want) {
+		t.Errorf("ScanServices() = %+v, want %+v", scanned, want)
+	}
+}
+
+func TestParserConfigEncoding(t *testing.T) {
+	config := ParserConfig{Encoding: EncodingLatin1}
+	services, err := config.GetServices(context.Background(), fixture("latin1.conf"))
+	if err != nil {
+		t.Fatalf("GetServices() error = %v", err)
+	}
+	if got, want := serviceNames(services), []string{"réseau", "svc2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetServices() = %v, want %v", got, want)
+	}
+	if got := services[0].Server.Name; got != "café" {
+		t.Errorf("service réseau server = %q, want %q", got, "café")
+	}
+	file, err := os.Open(fixture("latin1.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanned, err := config.ScanServices(context.Background(), file)
+	if err != nil {
+		t.Fatalf("ScanServices() error = %v", err)
+	}
+	if !reflect.DeepEqual(scanned, services) {
+		t.Errorf("ScanServices() = %+v, want %+v", scanned, services)
+	}
+	added, removed, err := config.DiffUSIP(context.Background(), fixture("latin1.conf"), fixture("latin1_after.conf"))
+	if err != nil {
+		t.Fatalf("DiffUSIP() error = %v", err)
+	}
+	if got, want := serviceNames(added), []string{"dépôt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffUSIP() added %v, want %v", got, want)
+	}
+	if got, want := serviceNames(removed), []string{"réseau"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffUSIP() removed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package netscaler_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"usipProject/netscaler"
+)
+
+func ExampleParseServices() {
+	config := `add server web1 10.0.0.1
+add server web2 10.0.0.2
+add service svc1 web1 HTTP 80 -usip YES
+add service svc2 web2 SSL 443 -usip NO
+`
+	services, err := netscaler.ParseServices(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, service := range services {
+		fmt.Println(service.Name, service.Server.IPAddress, service.HasUSIP())
+	}
+	// Output:
+	// svc1 10.0.0.1 true
+	// svc2 10.0.0.2 false
+}
+
+func ExampleWriteResults() {
+	services, err := netscaler.ParseServices(`add server web1 10.0.0.1
+add service svc1 web1 HTTP 80 -usip YES
+add service svc2 web1 HTTP 8080
+`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	services = netscaler.FilterByUSIP(services, netscaler.USIPModeYes)
+	if err := netscaler.WriteResults(os.Stdout, services, "csv"); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// name,server,ip,protocol,port,usip
+	// svc1,web1,10.0.0.1,HTTP,80,YES
+}
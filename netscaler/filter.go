@@ -0,0 +1,236 @@
+package netscaler
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The modes accepted by FilterByUSIP.
+const (
+	USIPModeYes   = "yes"
+	USIPModeNo    = "no"
+	USIPModeUnset = "unset"
+	USIPModeAll   = "all"
+)
+
+// FilterByUSIP is a function that returns the services whose usip option matches mode.  USIPModeYes and USIPModeNo
+// select services with -usip YES (or ENABLED) and -usip NO (or DISABLED) respectively, USIPModeUnset selects services
+// without a -usip option and USIPModeAll returns every service.  An unknown mode selects nothing.
+func FilterByUSIP(services []Service, mode string) []Service {
+	var filtered []Service
+	for _, service := range services {
+		var match bool
+		switch mode {
+		case USIPModeYes:
+			match = service.HasUSIP()
+		case USIPModeNo:
+			match = normalizeUSIP(service.USIP) == "NO"
+		case USIPModeUnset:
+			match = normalizeUSIP(service.USIP) == ""
+		case USIPModeAll:
+			match = true
+		}
+		if match {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// serviceKey is a data structure for what identifies a service: its name, the name of its server and its port.  The
+// members of a service group all share the group's name, so the server and port keep them apart.
+type serviceKey struct {
+	name   string
+	server string
+	port   int
+}
+
+// serviceIdentity is a function that returns the key identifying service, used wherever two services are matched up
+// so that they are all matched the same way.
+func serviceIdentity(service Service) serviceKey {
+	return serviceKey{name: service.Name, server: service.Server.Name, port: service.Port}
+}
+
+// DedupeServices is a function that removes duplicate service definitions, such as a repeated "add service" line,
+// keeping the first occurrence.  Services are duplicates when they come from the same file and have the same
+// serviceIdentity.
+func DedupeServices(services []Service) []Service {
+	type fileServiceKey struct {
+		sourceFile string
+		serviceKey
+	}
+	seen := make(map[fileServiceKey]bool, len(services))
+	var deduped []Service
+	for _, service := range services {
+		key := fileServiceKey{service.SourceFile, serviceIdentity(service)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, service)
+	}
+	return deduped
+}
+
+// FilterByProtocol is a function that returns the services whose protocol is one of protocols.  protocols may be given
+// in any case, and are compared with the upper case protocol the parser gives services.  When protocols is empty every
+// service is returned.
+func FilterByProtocol(services []Service, protocols []string) []Service {
+	if len(protocols) == 0 {
+		return services
+	}
+	normalized := make([]string, len(protocols))
+	for ix, protocol := range protocols {
+		normalized[ix] = normalizeProtocol(protocol)
+	}
+	var filtered []Service
+	for _, service := range services {
+		for _, protocol := range normalized {
+			if service.Protocol == protocol {
+				filtered = append(filtered, service)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// FilterByNamePattern is a function that returns the services whose name matches pattern, or those whose name does
+// not match when exclude is true.  When pattern is nil every service is returned.
+func FilterByNamePattern(services []Service, pattern *regexp.Regexp, exclude bool) []Service {
+	if pattern == nil {
+		return services
+	}
+	var filtered []Service
+	for _, service := range services {
+		if pattern.MatchString(service.Name) != exclude {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// FilterByState is a function that returns the services whose administrative state is state, ignoring case, such as
+// StateEnabled or StateDisabled.  When state is empty every service is returned.
+func FilterByState(services []Service, state string) []Service {
+	if state == "" {
+		return services
+	}
+	var filtered []Service
+	for _, service := range services {
+		if strings.EqualFold(service.State, state) {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// FilterByCIDR is a function that returns the services whose server IP address is within the network cidr, such as
+// 10.0.0.0/8 or 2001:db8::/32.  Services with a domain-based server or an address that cannot be parsed are never
+// within the network.  An error is returned when cidr cannot be parsed.
+func FilterByCIDR(services []Service, cidr string) ([]Service, error) {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, err
+	}
+	var filtered []Service
+	for _, service := range services {
+		ip := net.ParseIP(service.Server.IPAddress)
+		if ip != nil && network.Contains(ip) {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered, nil
+}
+
+// FilterByServer is a function that returns the services whose server is named serverName.  When serverName is empty
+// every service is returned.
+func FilterByServer(services []Service, serverName string) []Service {
+	if serverName == "" {
+		return services
+	}
+	var filtered []Service
+	for _, service := range services {
+		if service.Server.Name == serverName {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// FilterByPortPredicate is a function that returns the services whose port satisfies pred.  A service on the
+// wildcard port is passed to pred as WildcardPort.
+func FilterByPortPredicate(services []Service, pred func(int) bool) []Service {
+	var filtered []Service
+	for _, service := range services {
+		if pred(service.Port) {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// NonstandardPort is a function that reports whether port is other than the standard HTTP and HTTPS ports, 80 and
+// 443, for use with FilterByPortPredicate.
+func NonstandardPort(port int) bool {
+	return port != 80 && port != 443
+}
+
+// NoServer is the key under which GroupByServer groups the services that have no server name.
+const NoServer = "(no server)"
+
+// GroupByServer is a function that returns services grouped by the name of their server, keeping the order in which
+// they were given within each group.  Services without a server name are grouped under NoServer.
+func GroupByServer(services []Service) map[string][]Service {
+	groups := make(map[string][]Service)
+	for _, service := range services {
+		key := service.Server.Name
+		if key == "" {
+			key = NoServer
+		}
+		groups[key] = append(groups[key], service)
+	}
+	return groups
+}
+
+// FindInconsistentUSIP is a function that returns, keyed by server name, the services of each server that hosts both
+// services with USIP enabled and services without it, which is often a misconfiguration.  Servers whose services all
+// agree, and services without a server name, are left out.
+func FindInconsistentUSIP(services []Service) map[string][]Service {
+	inconsistent := make(map[string][]Service)
+	for serverName, group := range GroupByServer(services) {
+		if serverName == NoServer {
+			continue
+		}
+		var usip int
+		for _, service := range group {
+			if service.HasUSIP() {
+				usip++
+			}
+		}
+		if usip != 0 && usip != len(group) {
+			inconsistent[serverName] = group
+		}
+	}
+	return inconsistent
+}
+
+// UniqueServerIPs is a function that returns the IP addresses of the servers of services with USIP enabled, sorted
+// numerically with IPv4 before IPv6 and each listed once, as needed for firewall rules.  Servers defined by domain name
+// have no IP address and are left out.
+func UniqueServerIPs(services []Service) []string {
+	seen := make(map[string]bool)
+	var ips []string
+	for _, service := range services {
+		ip := service.Server.IPAddress
+		if !service.HasUSIP() || ip == "" || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return ipLess(ips[i], ips[j]) })
+	return ips
+}
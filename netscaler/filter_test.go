@@ -0,0 +1,265 @@
+package netscaler
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// serviceNames is a function that returns the names of services, in order.
+func serviceNames(services []Service) []string {
+	names := []string{}
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	return names
+}
+
+// equalNames is a function that reports whether two lists of names are equal, treating nil and empty lists alike.
+func equalNames(a, b []string) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+// usipServices is a function that returns a service with each kind of usip value.
+func usipServices() []Service {
+	server := Server{Name: "web1", IPAddress: "10.0.0.1"}
+	return []Service{
+		NewService("yes", server, "HTTP", 80, "YES"),
+		NewService("no", server, "HTTP", 81, "NO"),
+		NewService("unset", server, "HTTP", 82, ""),
+		NewService("enabled", server, "HTTP", 83, "ENABLED"),
+	}
+}
+
+func TestFilterByUSIP(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{mode: USIPModeYes, want: []string{"yes", "enabled"}},
+		{mode: USIPModeNo, want: []string{"no"}},
+		{mode: USIPModeUnset, want: []string{"unset"}},
+		{mode: USIPModeAll, want: []string{"yes", "no", "unset", "enabled"}},
+		{mode: "maybe", want: []string{}},
+	}
+	for _, tt := range tests {
+		got := serviceNames(FilterByUSIP(usipServices(), tt.mode))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterByUSIP(%q) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDedupeServices(t *testing.T) {
+	services := getServices(t, "duplicate_services.conf")
+	if len(services) != 2 {
+		t.Fatalf("GetServices() returned %d services, want 2", len(services))
+	}
+	deduped := DedupeServices(services)
+	if len(deduped) != 1 {
+		t.Fatalf("DedupeServices() returned %d services, want 1", len(deduped))
+	}
+	if deduped[0].LineNumber != 2 {
+		t.Errorf("DedupeServices() kept the service on line %d, want the first on line 2", deduped[0].LineNumber)
+	}
+	other := services[1]
+	other.SourceFile = "other.conf"
+	if got := len(DedupeServices([]Service{services[0], other})); got != 2 {
+		t.Errorf("DedupeServices() of services from different files returned %d services, want 2", got)
+	}
+}
+
+func TestFilterByProtocol(t *testing.T) {
+	server := Server{Name: "web1", IPAddress: "10.0.0.1"}
+	services := []Service{
+		NewService("http", server, "HTTP", 80, "YES"),
+		NewService("ssl", server, "SSL", 443, "YES"),
+		NewService("tcp", server, "TCP", 25, "YES"),
+	}
+	tests := []struct {
+		protocols []string
+		want      []string
+	}{
+		{protocols: []string{"SSL"}, want: []string{"ssl"}},
+		{protocols: []string{"ssl", "Http"}, want: []string{"http", "ssl"}},
+		{protocols: []string{"GOPHER"}, want: []string{}},
+		{protocols: nil, want: []string{"http", "ssl", "tcp"}},
+	}
+	for _, tt := range tests {
+		got := serviceNames(FilterByProtocol(services, tt.protocols))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterByProtocol(%v) = %v, want %v", tt.protocols, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByNamePattern(t *testing.T) {
+	services := usipServices()
+	tests := []struct {
+		pattern string
+		exclude bool
+		want    []string
+	}{
+		{pattern: "^(yes|no)$", exclude: false, want: []string{"yes", "no"}},
+		{pattern: "^(yes|no)$", exclude: true, want: []string{"unset", "enabled"}},
+		{pattern: "e", exclude: true, want: []string{"no"}},
+		{pattern: "^monitor", exclude: false, want: nil},
+		{pattern: "^monitor", exclude: true, want: []string{"yes", "no", "unset", "enabled"}},
+	}
+	for _, tt := range tests {
+		got := serviceNames(FilterByNamePattern(services, regexp.MustCompile(tt.pattern), tt.exclude))
+		if !equalNames(got, tt.want) {
+			t.Errorf("FilterByNamePattern(%q, %v) = %v, want %v", tt.pattern, tt.exclude, got, tt.want)
+		}
+	}
+	if got := FilterByNamePattern(services, nil, true); len(got) != len(services) {
+		t.Errorf("FilterByNamePattern(nil) returned %d services, want %d", len(got), len(services))
+	}
+}
+
+func TestFilterByState(t *testing.T) {
+	services := getServices(t, "states.conf")
+	tests := []struct {
+		state string
+		want  []string
+	}{
+		{state: StateEnabled, want: []string{"enabled", "unspecified"}},
+		{state: "disabled", want: []string{"disabled"}},
+		{state: "", want: []string{"enabled", "disabled", "unspecified"}},
+	}
+	for _, tt := range tests {
+		if got := serviceNames(FilterByState(services, tt.state)); !equalNames(got, tt.want) {
+			t.Errorf("FilterByState(%q) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByCIDR(t *testing.T) {
+	services := []Service{
+		NewService("inside", Server{Name: "web1", IPAddress: "10.1.2.3"}, "HTTP", 80, "YES"),
+		NewService("outside", Server{Name: "web2", IPAddress: "192.168.0.1"}, "HTTP", 80, "YES"),
+		NewService("v6", Server{Name: "web3", IPAddress: "2001:db8::1"}, "HTTP", 80, "YES"),
+		NewService("domain", Server{Name: "web4", Domain: "web.example.com"}, "HTTP", 80, "YES"),
+		NewService("unparseable", Server{Name: "web5", IPAddress: "10.1.2"}, "HTTP", 80, "YES"),
+	}
+	tests := []struct {
+		cidr string
+		want []string
+	}{
+		{cidr: "10.0.0.0/8", want: []string{"inside"}},
+		{cidr: " 192.168.0.0/16 ", want: []string{"outside"}},
+		{cidr: "2001:db8::/32", want: []string{"v6"}},
+		{cidr: "172.16.0.0/12", want: nil},
+	}
+	for _, tt := range tests {
+		filtered, err := FilterByCIDR(services, tt.cidr)
+		if err != nil {
+			t.Errorf("FilterByCIDR(%q) error = %v", tt.cidr, err)
+			continue
+		}
+		if got := serviceNames(filtered); !equalNames(got, tt.want) {
+			t.Errorf("FilterByCIDR(%q) = %v, want %v", tt.cidr, got, tt.want)
+		}
+	}
+	for _, cidr := range []string{"10.0.0.0", "10.0.0.0/33", ""} {
+		if _, err := FilterByCIDR(services, cidr); err == nil {
+			t.Errorf("FilterByCIDR(%q) error = nil, want an error", cidr)
+		}
+	}
+}
+
+func TestFilterByServer(t *testing.T) {
+	services := getServices(t, "servers_services.conf")
+	tests := []struct {
+		server string
+		want   []string
+	}{
+		{server: "web1", want: []string{"svc1", "svc2"}},
+		{server: "web2", want: []string{"svc3"}},
+		{server: "web9", want: nil},
+		{server: "", want: []string{"svc1", "svc2", "svc3"}},
+	}
+	for _, tt := range tests {
+		if got := serviceNames(FilterByServer(services, tt.server)); !equalNames(got, tt.want) {
+			t.Errorf("FilterByServer(%q) = %v, want %v", tt.server, got, tt.want)
+		}
+	}
+	if got := serviceNames(FilterByUSIP(FilterByServer(services, "web1"), USIPModeYes)); !equalNames(got, []string{"svc1"}) {
+		t.Errorf("FilterByUSIP(FilterByServer(web1)) = %v, want [svc1]", got)
+	}
+}
+
+func TestGroupByServer(t *testing.T) {
+	services := []Service{
+		NewService("svc1", Server{Name: "web1", IPAddress: "10.0.0.1"}, "HTTP", 80, "YES"),
+		NewService("svc2", Server{Name: "web2", IPAddress: "10.0.0.2"}, "HTTP", 80, "NO"),
+		NewService("svc3", Server{Name: "web1", IPAddress: "10.0.0.1"}, "SSL", 443, "YES"),
+		NewService("svc4", Server{Name: "web3", IPAddress: "10.0.0.3"}, "HTTP", 80, ""),
+		NewService("svc5", Server{}, "HTTP", 80, "YES"),
+		NewService("svc6", Server{Name: "web1", IPAddress: "10.0.0.1"}, "TCP", 8080, "NO"),
+	}
+	groups := GroupByServer(services)
+	want := map[string][]string{
+		"web1":   {"svc1", "svc3", "svc6"},
+		"web2":   {"svc2"},
+		"web3":   {"svc4"},
+		NoServer: {"svc5"},
+	}
+	if len(groups) != len(want) {
+		t.Errorf("GroupByServer() returned %d groups, want %d", len(groups), len(want))
+	}
+	for serverName, names := range want {
+		if got := serviceNames(groups[serverName]); !reflect.DeepEqual(got, names) {
+			t.Errorf("GroupByServer()[%q] = %v, want %v", serverName, got, names)
+		}
+	}
+}
+
+func TestFilterByPortPredicate(t *testing.T) {
+	server := Server{Name: "web1", IPAddress: "10.0.0.1"}
+	services := []Service{
+		NewService("http", server, "HTTP", 80, "YES"),
+		NewService("https", server, "SSL", 443, "YES"),
+		NewService("alt", server, "SSL", 8443, "YES"),
+		NewService("any", server, "TCP", WildcardPort, "YES"),
+	}
+	tests := []struct {
+		description string
+		pred        func(int) bool
+		want        []string
+	}{
+		{description: "NonstandardPort", pred: NonstandardPort, want: []string{"alt", "any"}},
+		{description: "port 443", pred: func(port int) bool { return port == 443 }, want: []string{"https"}},
+		{description: "ports above 1024", pred: func(port int) bool { return port > 1024 }, want: []string{"alt"}},
+		{description: "wildcard port", pred: func(port int) bool { return port == WildcardPort }, want: []string{"any"}},
+		{description: "no port", pred: func(int) bool { return false }, want: nil},
+	}
+	for _, tt := range tests {
+		if got := serviceNames(FilterByPortPredicate(services, tt.pred)); !equalNames(got, tt.want) {
+			t.Errorf("FilterByPortPredicate(%s) = %v, want %v", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestFindInconsistentUSIP(t *testing.T) {
+	services := getServices(t, "inconsistent.conf")
+	services = append(services, NewService("orphan1", Server{}, "HTTP", 80, "YES"),
+		NewService("orphan2", Server{}, "HTTP", 81, "NO"))
+	inconsistent := FindInconsistentUSIP(services)
+	if len(inconsistent) != 1 {
+		t.Errorf("FindInconsistentUSIP() returned servers %v, want only web1", inconsistent)
+	}
+	if got, want := serviceNames(inconsistent["web1"]), []string{"svc1", "svc2", "svc3"}; !equalNames(got, want) {
+		t.Errorf("FindInconsistentUSIP()[web1] = %v, want %v", got, want)
+	}
+}
+
+func TestUniqueServerIPs(t *testing.T) {
+	got := UniqueServerIPs(getServices(t, "server_ips.conf"))
+	if want := []string{"10.0.0.9", "10.0.0.10", "2001:db8::1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueServerIPs() = %v, want %v", got, want)
+	}
+	if got := UniqueServerIPs(nil); len(got) != 0 {
+		t.Errorf("UniqueServerIPs(nil) = %v, want none", got)
+	}
+}
@@ -0,0 +1,38 @@
+package netscaler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzParseServices(f *testing.F) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.conf"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range fixtures {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(contents))
+	}
+	f.Add("add server web1 10.0.0.1\nadd service \"\" web1 HTTP 80\n")
+	f.Add("add server web1 10.0.0.1\nadd service q{} web1 HTTP *\n")
+	f.Fuzz(func(t *testing.T, file string) {
+		services, err := ParseServices(file)
+		if err != nil {
+			return
+		}
+		for _, service := range services {
+			if strings.TrimSpace(service.Name) == "" {
+				t.Errorf("ParseServices(%q) returned a service without a name: %+v", file, service)
+			}
+			if service.Port < 0 || service.Port > 65535 {
+				t.Errorf("ParseServices(%q) returned service %q with port %d", file, service.Name, service.Port)
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package netscaler
+
+import (
+	"strings"
+)
+
+// globalLinePattern matches the lines that turn NetScaler modes and features on and off.
+const globalLinePattern = `(?m)^[ \t]*(?:enable|disable)[ \t]+ns[ \t]+(?:mode|feature)[ \t].*`
+
+// GlobalDefaults is a data structure for the global settings of a NetScaler configuration.  Modes and Features hold
+// the modes and features turned on by "enable ns mode" and "enable ns feature" lines, in upper case.  USIP is the usip
+// value taken by services that do not set their own: YES when the USIP mode is enabled, NO when it is disabled and
+// empty when the configuration does not say.
+type GlobalDefaults struct {
+	Modes    []string `json:"modes,omitempty"`
+	Features []string `json:"features,omitempty"`
+	USIP     string   `json:"usip,omitempty"`
+}
+
+// ParseGlobalDefaults is a function that returns the global settings of the contents of a NetScaler configuration.
+// When a mode or feature is both enabled and disabled the last line wins.
+func ParseGlobalDefaults(file string) (GlobalDefaults, error) {
+	var defaults GlobalDefaults
+	globalLines, err := GetConfig(NormalizeLineEndings(file), globalLinePattern)
+	if err != nil {
+		return GlobalDefaults{}, err
+	}
+	for _, globalLine := range globalLines {
+		defaults.applyLine(globalLine)
+	}
+	return defaults, nil
+}
+
+// applyLine is a method that updates the defaults from an "enable ns mode", "disable ns mode", "enable ns feature" or
+// "disable ns feature" line.  Any other line is ignored.
+func (d *GlobalDefaults) applyLine(line string) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || (fields[0] != "enable" && fields[0] != "disable") || fields[1] != "ns" {
+		return
+	}
+	enable := fields[0] == "enable"
+	var list *[]string
+	switch fields[2] {
+	case "mode":
+		list = &d.Modes
+	case "feature":
+		list = &d.Features
+	default:
+		return
+	}
+	for _, name := range fields[3:] {
+		name = strings.ToUpper(name)
+		*list = removeName(*list, name)
+		if enable {
+			*list = append(*list, name)
+		}
+		if list == &d.Modes && name == "USIP" {
+			d.USIP = "NO"
+			if enable {
+				d.USIP = "YES"
+			}
+		}
+	}
+}
+
+// removeName is a function that returns names without name.
+func removeName(names []string, name string) []string {
+	kept := names[:0]
+	for _, n := range names {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// applyGlobalDefaults is a function that gives each of services without a usip value of its own the usip value of
+// defaults, marking it as inherited.
+func applyGlobalDefaults(services []Service, defaults GlobalDefaults) {
+	if defaults.USIP == "" {
+		return
+	}
+	for ix := range services {
+		if services[ix].USIP == "" {
+			services[ix].USIP = defaults.USIP
+			services[ix].USIPInherited = true
+		}
+	}
+}
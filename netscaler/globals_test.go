@@ -0,0 +1,61 @@
+package netscaler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGlobalDefaults(t *testing.T) {
+	tests := []struct {
+		file string
+		want GlobalDefaults
+	}{
+		{
+			file: readFixture(t, "global_usip.conf"),
+			want: GlobalDefaults{Modes: []string{"FR", "L3", "USIP"}, Features: []string{"LB"}, USIP: "YES"},
+		},
+		{
+			file: "enable ns mode usip\ndisable ns mode USIP\n",
+			want: GlobalDefaults{Modes: []string{}, USIP: "NO"},
+		},
+		{file: "add server web1 10.0.0.1\n", want: GlobalDefaults{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseGlobalDefaults(tt.file)
+		if err != nil {
+			t.Errorf("ParseGlobalDefaults(%q) error = %v", tt.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseGlobalDefaults(%q) = %+v, want %+v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesGlobalUSIP(t *testing.T) {
+	services := servicesByName(t, "global_usip.conf")
+	tests := []struct {
+		name      string
+		usip      string
+		inherited bool
+	}{
+		{name: "explicit_no", usip: "NO"},
+		{name: "explicit_yes", usip: "YES"},
+		{name: "inherited", usip: "YES", inherited: true},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.USIP != tt.usip || service.USIPInherited != tt.inherited {
+			t.Errorf("service %q USIP, USIPInherited = %q, %v, want %q, %v", tt.name, service.USIP,
+				service.USIPInherited, tt.usip, tt.inherited)
+		}
+		found, err := FindService(fixture("global_usip.conf"), tt.name)
+		if err != nil || found.USIP != tt.usip || found.USIPInherited != tt.inherited {
+			t.Errorf("FindService(%q) = %q, %v, %v, want %q, %v", tt.name, found.USIP, found.USIPInherited, err, tt.usip,
+				tt.inherited)
+		}
+	}
+	if got := services["inherited"].ConfigLine(); got != "add service inherited web1 HTTP 82" {
+		t.Errorf("ConfigLine() of an inherited usip = %q, want no -usip option", got)
+	}
+}
@@ -0,0 +1,95 @@
+package netscaler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Conflict is a data structure for a service that is defined differently in two of the sets given to MergeServices.
+// Kept is the value of Field in the first definition, which is the one MergeServices keeps, and Other is its value in
+// the later definition from OtherFile.
+type Conflict struct {
+	Name      string `json:"name"`
+	Field     string `json:"field"`
+	Kept      string `json:"kept"`
+	Other     string `json:"other"`
+	OtherFile string `json:"otherFile,omitempty"`
+}
+
+// String is a method that returns the conflict as a single line.
+func (c Conflict) String() string {
+	return fmt.Sprintf("service %q: %s %q differs from %q", c.Name, c.Field, c.Other, c.Kept)
+}
+
+// mergeFields are the attributes MergeServices compares, with how each is read from a service.  Where the service
+// was read from is not compared, as that is expected to differ between the sets.
+var mergeFields = []struct {
+	name  string
+	value func(Service) string
+}{
+	{"server", func(s Service) string { return s.Server.Name }},
+	{"ip", func(s Service) string { return s.Server.IPAddress }},
+	{"port", func(s Service) string { return FormatPort(s.Port) }},
+	{"protocol", func(s Service) string { return s.Protocol }},
+	{"usip", func(s Service) string { return s.USIP }},
+	{"cip", func(s Service) string { return strconv.FormatBool(s.CIP) }},
+	{"cipHeader", func(s Service) string { return s.CIPHeader }},
+	{"state", func(s Service) string { return s.State }},
+	{"maxClient", func(s Service) string { return strconv.Itoa(s.MaxClient) }},
+	{"maxReq", func(s Service) string { return strconv.Itoa(s.MaxReq) }},
+	{"cltTimeout", func(s Service) string { return strconv.Itoa(s.CltTimeout) }},
+	{"svrTimeout", func(s Service) string { return strconv.Itoa(s.SvrTimeout) }},
+	{"healthMonitor", func(s Service) string { return strconv.FormatBool(s.HealthMonitor) }},
+	{"monitors", func(s Service) string { return strings.Join(s.Monitors, ",") }},
+	{"certKey", func(s Service) string { return s.CertKey }},
+	{"vservers", func(s Service) string { return strings.Join(s.VServers, ",") }},
+}
+
+// MergeServices is a function that combines sets of services, such as those read from the two nodes of an HA pair,
+// into one.  A service found in more than one set is kept once, as first defined, and a Conflict is returned for each
+// attribute in which a later definition differs from it, including its server and port.  Services are the same when
+// they share a name, except for the members of a service group, which share the name of the group and are told apart
+// by their server and port as DedupeServices does.  A name is taken to be a service group when it appears more than
+// once within any of the sets.
+func MergeServices(sets ...[]Service) ([]Service, []Conflict) {
+	grouped := make(map[string]bool)
+	for _, services := range sets {
+		counts := make(map[string]int, len(services))
+		for _, service := range services {
+			counts[service.Name]++
+			if counts[service.Name] > 1 {
+				grouped[service.Name] = true
+			}
+		}
+	}
+	kept := make(map[serviceKey]Service)
+	var merged []Service
+	var conflicts []Conflict
+	for _, services := range sets {
+		for _, service := range services {
+			key := serviceKey{name: service.Name}
+			if grouped[service.Name] {
+				key = serviceIdentity(service)
+			}
+			first, ok := kept[key]
+			if !ok {
+				kept[key] = service
+				merged = append(merged, service)
+				continue
+			}
+			for _, field := range mergeFields {
+				if keptValue, otherValue := field.value(first), field.value(service); keptValue != otherValue {
+					conflicts = append(conflicts, Conflict{
+						Name:      service.Name,
+						Field:     field.name,
+						Kept:      keptValue,
+						Other:     otherValue,
+						OtherFile: service.SourceFile,
+					})
+				}
+			}
+		}
+	}
+	return merged, conflicts
+}
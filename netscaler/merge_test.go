@@ -0,0 +1,62 @@
+package netscaler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeServices(t *testing.T) {
+	primary := getServices(t, "servers_services.conf")
+	secondary := getServices(t, "servers_services.conf")
+	secondary[0].USIP = "NO"
+	secondary[0].SourceFile = "secondary.conf"
+	secondary = append(secondary, NewService("svc4", Server{Name: "web3", IPAddress: "10.0.0.3"}, "HTTP", 80, "YES"))
+	merged, conflicts := MergeServices(primary, secondary)
+	if got, want := serviceNames(merged), []string{"svc1", "svc2", "svc3", "svc4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeServices() = %v, want %v", got, want)
+	}
+	if merged[0].USIP != "YES" {
+		t.Errorf("MergeServices() kept svc1 with usip %q, want the first definition YES", merged[0].USIP)
+	}
+	want := []Conflict{{Name: "svc1", Field: "usip", Kept: "YES", Other: "NO", OtherFile: "secondary.conf"}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("MergeServices() conflicts = %+v, want %+v", conflicts, want)
+	}
+	if _, conflicts := MergeServices(primary, primary); len(conflicts) != 0 {
+		t.Errorf("MergeServices() of identical sets conflicts = %+v, want none", conflicts)
+	}
+}
+
+func TestMergeServicesByName(t *testing.T) {
+	primary := []Service{
+		NewService("svc1", Server{Name: "web1", IPAddress: "10.0.0.1"}, "HTTP", 80, "YES"),
+		NewService("sg1", Server{Name: "web1", IPAddress: "10.0.0.1"}, "HTTP", 80, "YES"),
+		NewService("sg1", Server{Name: "web2", IPAddress: "10.0.0.2"}, "HTTP", 80, "YES"),
+	}
+	secondary := []Service{
+		NewService("svc1", Server{Name: "web2", IPAddress: "10.0.0.2"}, "HTTP", 8080, "NO"),
+		NewService("sg1", Server{Name: "web2", IPAddress: "10.0.0.2"}, "HTTP", 80, "YES"),
+		NewService("sg1", Server{Name: "web3", IPAddress: "10.0.0.3"}, "HTTP", 80, "YES"),
+	}
+	merged, conflicts := MergeServices(primary, secondary)
+	want := []Service{primary[0], primary[1], primary[2], secondary[2]}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergeServices() = %+v, want %+v", merged, want)
+	}
+	wantConflicts := []Conflict{
+		{Name: "svc1", Field: "server", Kept: "web1", Other: "web2"},
+		{Name: "svc1", Field: "ip", Kept: "10.0.0.1", Other: "10.0.0.2"},
+		{Name: "svc1", Field: "port", Kept: "80", Other: "8080"},
+		{Name: "svc1", Field: "usip", Kept: "YES", Other: "NO"},
+	}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("MergeServices() conflicts = %+v, want %+v", conflicts, wantConflicts)
+	}
+}
+
+func TestConflictString(t *testing.T) {
+	conflict := Conflict{Name: "svc1", Field: "usip", Kept: "YES", Other: "NO"}
+	if got, want := conflict.String(), `service "svc1": usip "NO" differs from "YES"`; got != want {
+		t.Errorf("Conflict.String() = %q, want %q", got, want)
+	}
+}
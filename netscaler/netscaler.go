@@ -0,0 +1,1618 @@
+// Package netscaler parses NetScaler configurations into their servers, Load Balancing services, service groups and
+// virtual servers, and writes the services that use usip (use source IP address) in several output formats.
+package netscaler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// logger is the logger for the parse functions.  It discards everything unless SetLogOutput gives it somewhere to
+// write.
+var logger = log.New(ioutil.Discard, "", log.LstdFlags)
+
+// SetLogOutput is a function that sets the destination of the parsing details logged by the parse functions.  They are
+// discarded by default.
+func SetLogOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// ErrServerNotFound is the error returned, wrapped with the server name, when a server is not defined in the
+// configuration.  Callers can test for it with errors.Is.
+var ErrServerNotFound = errors.New("server not found")
+
+// Server is a data structure for NetScaler server data.
+type Server struct {
+	Name      string `json:"name"`
+	IPAddress string `json:"ip"`
+	Domain    string `json:"domain,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// AddressFamily is a type for the IP address family of a server.
+type AddressFamily string
+
+// The address families reported by Server.AddressFamily.  AddressFamilyNone is returned for servers whose address is
+// not a literal IP address.
+const (
+	AddressFamilyNone AddressFamily = ""
+	AddressFamilyIPv4 AddressFamily = "IPv4"
+	AddressFamilyIPv6 AddressFamily = "IPv6"
+)
+
+// AddressFamily is a method that reports whether the server's address is an IPv4 or IPv6 address.
+func (s Server) AddressFamily() AddressFamily {
+	return AddressFamilyOf(s.IPAddress)
+}
+
+// Address is a method that returns the IP address of the server, or its domain name for servers defined by domain.
+func (s Server) Address() string {
+	if s.IPAddress == "" {
+		return s.Domain
+	}
+	return s.IPAddress
+}
+
+// AddressFamilyOf is a function that reports whether address is an IPv4 or IPv6 address.
+func AddressFamilyOf(address string) AddressFamily {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return AddressFamilyNone
+	}
+	if ip.To4() != nil {
+		return AddressFamilyIPv4
+	}
+	return AddressFamilyIPv6
+}
+
+// Service is a data structure for NetScaler Load Balancing service data.
+type Service struct {
+	Name          string         `json:"name"`
+	Server        Server         `json:"server"`
+	Protocol      string         `json:"protocol"`
+	RawProtocol   string         `json:"rawProtocol,omitempty"`
+	Port          int            `json:"port"`
+	USIP          string         `json:"usip"`
+	USIPInherited bool           `json:"usipInherited,omitempty"`
+	CIP           bool           `json:"cip"`
+	State         string         `json:"state"`
+	MaxClient     int            `json:"maxClient,omitempty"`
+	MaxReq        int            `json:"maxReq,omitempty"`
+	CltTimeout    int            `json:"cltTimeout,omitempty"`
+	SvrTimeout    int            `json:"svrTimeout,omitempty"`
+	HealthMonitor bool           `json:"healthMonitor"`
+	CIPHeader     string         `json:"cipHeader,omitempty"`
+	Monitors      []string       `json:"monitors,omitempty"`
+	CertKey       string         `json:"certKey,omitempty"`
+	VServers      []string       `json:"vservers,omitempty"`
+	SourceFile    string         `json:"sourceFile,omitempty"`
+	LineNumber    int            `json:"lineNumber,omitempty"`
+	RawLine       string         `json:"rawLine,omitempty"`
+	Warnings      []ParseWarning `json:"warnings,omitempty"`
+}
+
+// The administrative states of a service.  A service without a -state option is enabled.
+const (
+	StateEnabled  = "ENABLED"
+	StateDisabled = "DISABLED"
+)
+
+// WildcardPort is the Port value of a service configured with the NetScaler "*" port.
+const WildcardPort = 0
+
+// NewService is a function that returns an enabled, health monitored Service built from its component values.
+func NewService(name string, server Server, protocol string, port int, usip string) Service {
+	return Service{
+		Name:          name,
+		Server:        server,
+		Protocol:      protocol,
+		Port:          port,
+		USIP:          usip,
+		State:         StateEnabled,
+		HealthMonitor: true,
+	}
+}
+
+// HasUSIP is a method that reports whether the service uses the source IP address.  The usip value is compared after
+// normalizeUSIP, so "YES", "Yes", "YES " and "ENABLED" are all enabled.
+func (s Service) HasUSIP() bool {
+	return normalizeUSIP(s.USIP) == "YES"
+}
+
+// normalizeUSIP is a function that returns a usip value trimmed of whitespace and in upper case, with the ENABLED and
+// DISABLED spellings used by some configurations mapped to YES and NO.
+func normalizeUSIP(usip string) string {
+	usip = strings.ToUpper(strings.TrimSpace(usip))
+	switch usip {
+	case "ENABLED":
+		return "YES"
+	case "DISABLED":
+		return "NO"
+	}
+	return usip
+}
+
+// ConfigLine is a method that returns the "add service" command that defines the service, so that a parsed service can
+// be written back into a configuration.  Names are quoted when they need to be, and the -usip, -cip, -cipHeader,
+// -maxClient, -maxReq, -cltTimeout, -svrTimeout, -healthMonitor and -state options are only included when they differ
+// from the defaults.  Parsing the returned line gives back the same service.
+func (s Service) ConfigLine() string {
+	fields := []string{"add service", quoteName(s.Name), quoteName(s.Server.Name), s.Protocol, FormatPort(s.Port)}
+	if s.USIP != "" && !s.USIPInherited {
+		fields = append(fields, "-usip", s.USIP)
+	}
+	if s.CIP {
+		fields = append(fields, "-cip", "ENABLED")
+	}
+	if s.CIPHeader != "" {
+		fields = append(fields, "-cipHeader", s.CIPHeader)
+	}
+	if s.MaxClient != 0 {
+		fields = append(fields, "-maxClient", strconv.Itoa(s.MaxClient))
+	}
+	if s.MaxReq != 0 {
+		fields = append(fields, "-maxReq", strconv.Itoa(s.MaxReq))
+	}
+	if s.CltTimeout != 0 {
+		fields = append(fields, "-cltTimeout", strconv.Itoa(s.CltTimeout))
+	}
+	if s.SvrTimeout != 0 {
+		fields = append(fields, "-svrTimeout", strconv.Itoa(s.SvrTimeout))
+	}
+	if !s.HealthMonitor {
+		fields = append(fields, "-healthMonitor", "NO")
+	}
+	if s.State != "" && s.State != StateEnabled {
+		fields = append(fields, "-state", s.State)
+	}
+	return strings.Join(fields, " ")
+}
+
+// quoteName is a function that returns name as it is written in a configuration.  A name that is empty, contains a
+// space or a quote, or would be read as a q-delimited string is surrounded by quotes, with any quotes or backslashes
+// within it escaped as RemoveQuote expects.
+func quoteName(name string) string {
+	_, qDelimited := removeQDelimiters(name)
+	if name != "" && !strings.ContainsAny(name, " \t\"") && !qDelimited && !quoteRegexp.MatchString(name) {
+		return name
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + escaper.Replace(name) + `"`
+}
+
+// Config is a data structure for the complete parsed NetScaler configuration.
+type Config struct {
+	Servers       []Server       `json:"servers"`
+	Services      []Service      `json:"services"`
+	ServiceGroups []ServiceGroup `json:"serviceGroups"`
+	VServers      []VServer      `json:"vservers"`
+	Defaults      GlobalDefaults `json:"defaults"`
+}
+
+// GetFile is a function that gets access to a file based on the file name, which may also be an http or https URL.
+// Line endings are normalized and a leading UTF-8 byte order mark is removed, so that files saved on Windows parse the
+// same as any other.
+func GetFile(fileName string) (string, error) {
+	return loadSource(fileName)
+}
+
+// ReadConfig is a function that reads the whole of r and returns it with its line endings normalized and any leading
+// byte order mark removed.
+func ReadConfig(r io.Reader) (string, error) {
+	file, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	file, err = Decompress(file)
+	if err != nil {
+		return "", err
+	}
+	return RemoveBOM(NormalizeLineEndings(string(file))), nil
+}
+
+// Decompress is a function that returns the decompressed contents of a gzip-compressed configuration, such as a
+// .conf.gz backup.  Compression is detected from the gzip magic bytes, so any other contents are returned unchanged.
+func Decompress(file []byte) ([]byte, error) {
+	if !bytes.HasPrefix(file, []byte{0x1f, 0x8b}) {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// NormalizeLineEndings is a function that removes the carriage returns of Windows (\r\n) and classic Mac (\r) line
+// endings, leaving a single \n at the end of each line.
+func NormalizeLineEndings(file string) string {
+	file = strings.Replace(file, "\r\n", "\n", -1)
+	return strings.Replace(file, "\r", "\n", -1)
+}
+
+// RemoveComments is a function that blanks the comment lines, those starting with "#", within the contents of a file so
+// that commented-out commands are not parsed.  The lines themselves are kept so that line numbers do not change.
+func RemoveComments(file string) string {
+	if !strings.Contains(file, "#") {
+		return file
+	}
+	lines := strings.Split(file, "\n")
+	for ix, line := range lines {
+		if isComment(line) {
+			lines[ix] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isComment is a function that reports whether line is a comment line.
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// GetConfig is a function that takes the contents of a file as a parameter as well as
+// a pattern to use as a filter to return results as strings.  Comment lines are ignored.
+func GetConfig(file, pattern string) ([]string, error) {
+	regexer, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	results := regexer.FindAllString(RemoveComments(file), -1)
+	return results, nil
+}
+
+// ConfigLine is a data structure for a line of a NetScaler configuration matched by GetConfigLines.
+type ConfigLine struct {
+	Text   string
+	Number int
+}
+
+// GetConfigLines is a function that takes the contents of a file as a parameter as well as a pattern to use as a
+// filter, and returns each match along with the 1-based number of the line on which it starts.  Comment lines are
+// ignored.
+func GetConfigLines(file, pattern string) ([]ConfigLine, error) {
+	regexer, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return findConfigLines(file, regexer), nil
+}
+
+// findConfigLines is a function that returns each match of an already compiled regular expression within the contents
+// of a file, as GetConfigLines does for a pattern.
+func findConfigLines(file string, regexer *regexp.Regexp) []ConfigLine {
+	file = RemoveComments(file)
+	var results []ConfigLine
+	lineNumber, offset := 1, 0
+	for _, index := range regexer.FindAllStringIndex(file, -1) {
+		lineNumber += strings.Count(file[offset:index[0]], "\n")
+		offset = index[0]
+		results = append(results, ConfigLine{Text: file[index[0]:index[1]], Number: lineNumber})
+	}
+	return results
+}
+
+// RemoveConfigKeywords is a function that removes the CLI keywords from within a NetScaler configuration.  The
+// keywords in the line may be separated by any run of spaces and tabs, so "add  service" and "add\tservice" are
+// removed as "add service" is.
+func RemoveConfigKeywords(textLine, pattern string) string {
+	loc := keywordRegexp(pattern).FindStringIndex(textLine)
+	if loc == nil {
+		return textLine
+	}
+	return textLine[:loc[0]] + textLine[loc[1]:]
+}
+
+// keywordRegexps caches the regular expressions built by keywordRegexp, by keywords.
+var keywordRegexps sync.Map
+
+// keywordRegexp is a function that returns the regular expression matching the CLI keywords separated by any run of
+// spaces and tabs.  Trailing whitespace in keywords matches any run of whitespace too.
+func keywordRegexp(keywords string) *regexp.Regexp {
+	if cached, ok := keywordRegexps.Load(keywords); ok {
+		return cached.(*regexp.Regexp)
+	}
+	words := strings.Fields(keywords)
+	for ix, word := range words {
+		words[ix] = regexp.QuoteMeta(word)
+	}
+	pattern := strings.Join(words, `[ \t]+`)
+	if strings.TrimRight(keywords, " \t") != keywords {
+		pattern += `[ \t]+`
+	}
+	compiled := regexp.MustCompile(pattern)
+	keywordRegexps.Store(keywords, compiled)
+	return compiled
+}
+
+// quotePattern is the regular expression for a string surrounded by quotes.  A quote within the string is escaped
+// with a backslash, as in "svc\"weird\"name", and does not end the string.
+const quotePattern = `"(?:[^"\\]|\\.)*"`
+
+// qDelimiters holds the pairs of opening and closing delimiters of the q-delimited strings, such as q/web "1"/ or
+// q{web 1}, that NetScaler also accepts for names containing spaces or quotes.  Nothing within a q-delimited string is
+// escaped, so it ends at the first closing delimiter.
+const qDelimiters = "{}<>()[]//||~~''\"\"$$^^++==&&%%@@??"
+
+// qDelimitedPattern is a function that returns the regular expression for a q-delimited string with any of the
+// qDelimiters.  The q must start a word, so a name such as "seq/1" is not mistaken for one.
+func qDelimitedPattern() string {
+	var alternatives []string
+	for ix := 0; ix+1 < len(qDelimiters); ix += 2 {
+		opening, closing := regexp.QuoteMeta(qDelimiters[ix:ix+1]), regexp.QuoteMeta(qDelimiters[ix+1:ix+2])
+		alternatives = append(alternatives, opening+`[^`+closing+`]*`+closing)
+	}
+	return `\bq(?:` + strings.Join(alternatives, "|") + `)`
+}
+
+// quoteRegexp and noQuoteRegexp are compiled once rather than on every call, because the quote functions are called
+// for every line of the configuration.  quoteRegexp matches both classic and q-delimited strings.  noQuoteRegexp
+// matches any run of non-space characters, so that a name starting with a character such as [ or * that is special in
+// regular expressions is taken whole.
+var (
+	quoteRegexp   = regexp.MustCompile(quotePattern + "|" + qDelimitedPattern())
+	noQuoteRegexp = regexp.MustCompile(`\S+\s`)
+)
+
+// QuoteIndex is a function that returns a 2D slice of integers.  This function helps to determine if there is a
+// quote within a slice of strings.  If there is a quote this means that there is a space within a string and will
+// have to be dealt with.  At the point in which this function is called, there may or may not be a quote in the first
+// position of the string which is accepted as the parameter.
+func QuoteIndex(line string) ([][]int, error) {
+	result := quoteRegexp.FindAllStringIndex(line, 1)
+	return result, nil
+}
+
+// ExtractQuote is a function that uses a regular expression to extract strings that are surrounded by quotes.
+// This function returns a string with the quotes.
+func ExtractQuote(line string) (string, error) {
+	result := quoteRegexp.FindString(line)
+	return result, nil
+}
+
+// RemoveQuote is a function that removes quotes from a string.  When the whole string is surrounded by quotes, only
+// the surrounding quotes are removed and any escaped quotes or backslashes within it are unescaped.  When the whole
+// string is q-delimited, the q and its delimiters are removed and the rest is returned as it is.
+func RemoveQuote(line string) string {
+	if inner, ok := removeQDelimiters(line); ok {
+		return inner
+	}
+	if len(line) >= 2 && strings.HasPrefix(line, "\"") && strings.HasSuffix(line, "\"") {
+		unescaper := strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+		return unescaper.Replace(line[1 : len(line)-1])
+	}
+	pattern := "\""
+	result := strings.Replace(line, pattern, "", -1)
+	return result
+}
+
+// removeQDelimiters is a function that returns the contents of line and true when the whole of line is a q-delimited
+// string, and false otherwise.
+func removeQDelimiters(line string) (string, bool) {
+	if len(line) < 3 || line[0] != 'q' {
+		return "", false
+	}
+	ix := strings.IndexByte(qDelimiters, line[1])
+	if ix < 0 || ix%2 != 0 || line[len(line)-1] != qDelimiters[ix+1] {
+		return "", false
+	}
+	return line[2 : len(line)-1], true
+}
+
+// ExtractNoQuote is a function that extracts the first string followed by a space, that does not
+// include a quote within the string.  While not intuitive, it works well with how the NetScaler config
+// file is constructed.
+func ExtractNoQuote(line string) (string, error) {
+	result := noQuoteRegexp.FindString(line)
+	return result, nil
+}
+
+// ParseServerLine is a function that accepts an "add server" line with the CLI keywords already removed and returns
+// the Server it defines.  A line without a server name or an address is an error.
+func ParseServerLine(serverLine string) (Server, error) {
+	serverLine = strings.TrimSpace(serverLine)
+	quoteIndex, err := QuoteIndex(serverLine)
+	if err != nil {
+		return Server{}, err
+	}
+	var server Server
+	var remainder string
+	length := len(quoteIndex)
+	if length != 0 {
+		intSlice := quoteIndex[0][0]
+		if intSlice == 0 {
+			extractedQuote, err := ExtractQuote(serverLine)
+			if err != nil {
+				return Server{}, err
+			}
+			lineTrim := strings.TrimSpace(extractedQuote)
+			server.Name = RemoveQuote(lineTrim)
+			remainder = strings.Replace(serverLine, lineTrim, "", 1)
+		} else {
+			// There are instances where comments are added to the server configuration.  This block handles
+			// situations where comments are included.  Because comments are included, there are additional quotes
+			// surrounding the comments, but the name itself cannot contain a space, so it is the first field.
+			server.Name = strings.Fields(serverLine)[0]
+			remainder = strings.TrimPrefix(serverLine, server.Name)
+		}
+	} else {
+		serverLineArray := strings.Fields(serverLine)
+		if len(serverLineArray) != 0 {
+			server.Name = serverLineArray[0]
+			remainder = strings.Join(serverLineArray[1:], " ")
+		}
+	}
+	if server.Name == "" {
+		return Server{}, &tokenError{end: true, err: errors.New("missing server name")}
+	}
+	address, err := ExtractAddress(remainder)
+	if err != nil {
+		return Server{}, err
+	}
+	if address == "" {
+		return Server{}, &tokenError{end: true, err: fmt.Errorf("server %s: missing address", server.Name)}
+	}
+	SetServerAddress(&server, address)
+	server.Comment = ExtractComment(remainder)
+	return server, nil
+}
+
+// ExtractAddress is a function that returns the address from the remainder of an "add server" line once the server
+// name has been removed.  The address is the first token that is neither an option, the value of an option nor a
+// quoted comment, so an address given to an option such as -translationIp is never taken for the server's own.  An
+// unquoted trailing comment, as in add server web1 10.0.0.5 #legacy, runs from the token starting with # to the end of
+// the line and is ignored.  The address is an IPv4 or IPv6 address, or the domain name of a domain-based server.
+func ExtractAddress(remainder string) (string, error) {
+	// Quoted strings are replaced by a placeholder rather than removed, so that a quoted option value is still skipped
+	// with its option.
+	const quoted = `""`
+	fields := strings.Fields(quoteRegexp.ReplaceAllString(remainder, " "+quoted+" "))
+	for ix := 0; ix < len(fields); ix++ {
+		field := fields[ix]
+		switch {
+		case strings.HasPrefix(field, "#"):
+			return "", nil
+		case strings.HasPrefix(field, "-"):
+			ix++
+		case field != quoted:
+			return field, nil
+		}
+	}
+	return "", nil
+}
+
+// commentRegexp matches the -comment option and the whitespace after it.
+var commentRegexp = regexp.MustCompile(`(?:^|\s)-comment\s+`)
+
+// ExtractComment is a function that returns the value of the -comment option from the remainder of a line, or an
+// empty string if there is none.  A quoted or q-delimited comment has its quotes removed, so it may contain spaces and
+// quotes of its own.  A -comment within another quoted value is not an option and is ignored.
+func ExtractComment(remainder string) string {
+	quoted := quoteRegexp.FindAllStringIndex(remainder, -1)
+	for _, option := range commentRegexp.FindAllStringIndex(remainder, -1) {
+		if withinQuote(quoted, option[0]) {
+			continue
+		}
+		for _, quote := range quoted {
+			if quote[0] == option[1] {
+				return RemoveQuote(remainder[quote[0]:quote[1]])
+			}
+		}
+		if fields := strings.Fields(remainder[option[1]:]); len(fields) != 0 {
+			return fields[0]
+		}
+		return ""
+	}
+	return ""
+}
+
+// withinQuote is a function that reports whether the byte at ix falls inside one of the quoted strings at the given
+// indexes, as returned by FindAllStringIndex.
+func withinQuote(quoted [][]int, ix int) bool {
+	for _, quote := range quoted {
+		if ix > quote[0] && ix < quote[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// SetServerAddress is a function that stores the address token of an "add server" line on the server.  Servers
+// defined by domain name, usually alongside the -domainResolveRetry option, have the name stored in Domain and leave
+// IPAddress empty.
+func SetServerAddress(server *Server, address string) {
+	if address == "" || net.ParseIP(address) != nil {
+		server.IPAddress = address
+		return
+	}
+	server.Domain = address
+}
+
+// GetServers is a function that accepts a file name as a parameter and returns every Server defined within the
+// NetScaler configuration, in the order in which they appear.
+func GetServers(fileName string) ([]Server, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParserConfig{}.parseServers(file)
+}
+
+// parseServers is a method that returns every Server defined within the contents of a NetScaler configuration.  A
+// malformed "add server" line is logged and left out rather than failing the parse, as no service may use it.
+func (c ParserConfig) parseServers(file string) ([]Server, error) {
+	addServerLines, err := GetConfigLines(file, c.serverPattern())
+	if err != nil {
+		return nil, err
+	}
+	var servers []Server
+	for _, addServerLine := range addServerLines {
+		server, err := parseAddServerLine(addServerLine)
+		if err != nil {
+			logger.Printf("skipping server: %v", err)
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// parseAddServerLine is a function that returns the Server defined by an "add server" line matched from a NetScaler
+// configuration.  An error is returned as a ParseError.
+func parseAddServerLine(addServerLine ConfigLine) (Server, error) {
+	serverLine := RemoveConfigKeywords(addServerLine.Text, "add server")
+	server, err := ParseServerLine(serverLine)
+	if err != nil {
+		return Server{}, newParseError(addServerLine, "malformed add server", err)
+	}
+	return server, nil
+}
+
+// BuildServer is a function that accepts a file name as a parameter as well as server name as a string and returns a
+// single Server type.  Callers looking up more than one server should build a ServerIndex once instead.
+func BuildServer(fileName, serverName string) (Server, error) {
+	index, err := BuildServerIndex(fileName)
+	if err != nil {
+		return Server{}, err
+	}
+	return lookupServer(index, serverName)
+}
+
+// ServerIndex is a data structure that holds the servers of a NetScaler configuration keyed by name, so that services
+// can look up their server without rescanning the whole file.
+type ServerIndex struct {
+	servers map[string]Server
+	// malformed holds the errors of the malformed "add server" lines, by server name, for lookupServer to report.
+	malformed map[string]error
+	// foldCase makes names match regardless of case.
+	foldCase bool
+}
+
+// NewServerIndex is a function that returns a ServerIndex holding servers.  When a name appears more than once the
+// first server is kept, matching the order in which NetScaler reads the configuration.
+func NewServerIndex(servers []Server) *ServerIndex {
+	return newServerIndex(servers, false)
+}
+
+// newServerIndex is a function that returns a ServerIndex holding servers, whose names match regardless of case when
+// foldCase is true.
+func newServerIndex(servers []Server, foldCase bool) *ServerIndex {
+	index := &ServerIndex{servers: make(map[string]Server, len(servers)), foldCase: foldCase}
+	for _, server := range servers {
+		index.add(server)
+	}
+	return index
+}
+
+// add is a method that adds server to the index unless a server with the same name is already held.
+func (i *ServerIndex) add(server Server) {
+	key := i.key(server.Name)
+	if _, ok := i.servers[key]; !ok {
+		i.servers[key] = server
+	}
+}
+
+// addLine is a method that adds the server defined by an "add server" line to the index.  A malformed line is not an
+// error here, since no service may use the server; its error is kept instead, and returned by lookupServer to the
+// services that do.
+func (i *ServerIndex) addLine(addServerLine ConfigLine) {
+	server, err := parseAddServerLine(addServerLine)
+	if err == nil {
+		i.add(server)
+		return
+	}
+	logger.Printf("skipping server: %v", err)
+	name, _, nameErr := ExtractName(strings.TrimSpace(RemoveConfigKeywords(addServerLine.Text, "add server")))
+	if nameErr != nil {
+		return
+	}
+	key := i.key(name)
+	if _, ok := i.malformed[key]; ok {
+		return
+	}
+	if i.malformed == nil {
+		i.malformed = make(map[string]error)
+	}
+	i.malformed[key] = err
+}
+
+// BuildServerIndex is a function that accepts a file name as a parameter and returns a ServerIndex of every server
+// defined within it.
+func BuildServerIndex(fileName string) (*ServerIndex, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParserConfig{}.parseServerIndex(file)
+}
+
+// parseServerIndex is a method that returns a ServerIndex of the servers defined within the contents of a NetScaler
+// configuration.
+func (c ParserConfig) parseServerIndex(file string) (*ServerIndex, error) {
+	addServerLines, err := GetConfigLines(file, c.serverPattern())
+	if err != nil {
+		return nil, err
+	}
+	index := newServerIndex(nil, c.CaseInsensitiveNames)
+	for _, addServerLine := range addServerLines {
+		index.addLine(addServerLine)
+	}
+	return index, nil
+}
+
+// ValidateServers is a function that accepts a file name as a parameter and returns a ParseWarning for each "add
+// server" line that redefines an earlier server with a different address.  Only the first definition is used when
+// services look up their server, so the later addresses are silently ignored otherwise.
+func ValidateServers(fileName string) ([]ParseWarning, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := ParserConfig{}.parseServers(file)
+	if err != nil {
+		return nil, err
+	}
+	return validateServers(servers), nil
+}
+
+// validateServers is a function that returns a ParseWarning for each of servers that has the same name as an earlier
+// server but a different address.
+func validateServers(servers []Server) []ParseWarning {
+	var warnings []ParseWarning
+	first := make(map[string]Server, len(servers))
+	for _, server := range servers {
+		original, ok := first[server.Name]
+		if !ok {
+			first[server.Name] = server
+			continue
+		}
+		if server.Address() != original.Address() {
+			warnings = append(warnings, ParseWarning{
+				Field:  "server",
+				Value:  server.Name,
+				Reason: fmt.Sprintf("redefined with address %s, keeping %s", server.Address(), original.Address()),
+			})
+		}
+	}
+	return warnings
+}
+
+// Lookup is a method that returns the server with the given name and whether it was found.
+func (i *ServerIndex) Lookup(serverName string) (Server, bool) {
+	server, ok := i.servers[i.key(serverName)]
+	return server, ok
+}
+
+// key is a method that returns the key that the server named serverName is held under.
+func (i *ServerIndex) key(serverName string) string {
+	if i.foldCase {
+		return strings.ToLower(serverName)
+	}
+	return serverName
+}
+
+// lookupServer is a function that returns the server with the given name from index, or an error if there is none.
+// As on the NetScaler, a service may name an IP address instead of a server; the address may be an IPv6 address in
+// brackets, with or without a port, as in [2001:db8::1]:443.  The server of that name is returned when there is one,
+// and otherwise a server named after the address.  A server whose "add server" line is malformed is missing, and the
+// error says why.
+func lookupServer(index *ServerIndex, serverName string) (Server, error) {
+	server, ok := index.Lookup(serverName)
+	if !ok {
+		address := serverAddress(serverName)
+		if malformedErr, ok := index.malformed[index.key(serverName)]; ok && net.ParseIP(address) == nil {
+			err := fmt.Errorf("%w: %s (%v)", ErrServerNotFound, serverName, malformedErr)
+			return Server{}, &tokenError{token: serverName, err: err}
+		}
+		if net.ParseIP(address) == nil {
+			logger.Printf("server %q not found", serverName)
+			return Server{}, &tokenError{token: serverName, err: fmt.Errorf("%w: %s", ErrServerNotFound, serverName)}
+		}
+		server, ok = index.Lookup(address)
+		if !ok {
+			server = Server{Name: address, IPAddress: address}
+		}
+	}
+	logger.Printf("found server %q with address %q", serverName, server.Address())
+	return server, nil
+}
+
+// serverAddress is a function that returns the address within a bracketed IPv6 address such as [2001:db8::1] or
+// [2001:db8::1]:443, and serverName unchanged otherwise.
+func serverAddress(serverName string) string {
+	if !strings.HasPrefix(serverName, "[") {
+		return serverName
+	}
+	if host, _, err := net.SplitHostPort(serverName); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(serverName, "["), "]")
+}
+
+// GetServices is a function that returns an array of Load Balancing services.  It accepts a filename
+// as a parameter.
+func GetServices(fileName string) ([]Service, error) {
+	return GetServicesContext(context.Background(), fileName)
+}
+
+// GetServicesContext is a function that returns an array of Load Balancing services like GetServices, but stops and
+// returns ctx.Err() if ctx is cancelled before the parse completes.
+func GetServicesContext(ctx context.Context, fileName string) ([]Service, error) {
+	return ParserConfig{}.GetServices(ctx, fileName)
+}
+
+// GetServicesWithHook is a function that returns an array of Load Balancing services like GetServices, with each
+// service replaced by the result of calling hook on it before it is returned.
+func GetServicesWithHook(fileName string, hook func(Service) Service) ([]Service, error) {
+	return ParserConfig{Hook: hook}.GetServices(context.Background(), fileName)
+}
+
+// GetServicesReader is a function that returns an array of Load Balancing services read from r, such as os.Stdin.
+func GetServicesReader(r io.Reader) ([]Service, error) {
+	file, err := ReadConfig(r)
+	if err != nil {
+		return []Service{}, err
+	}
+	return ParseServices(file)
+}
+
+// ParseServiceLine is a function that accepts an "add service" line with the CLI keywords already removed and returns
+// the Service it defines, looking up its server in servers.  The returned bool is false when the line is in a form
+// that is not handled and should be skipped.  A service without a -state option is enabled.  The protocol is upper
+// cased, with the protocol as written kept in RawProtocol.  A service with a suspect value is returned with a
+// ParseWarning.
+func ParseServiceLine(serviceLine string, servers *ServerIndex) (Service, bool, error) {
+	service, ok, err := parseServiceLine(serviceLine, servers)
+	if err != nil || !ok {
+		return service, ok, err
+	}
+	if service.State == "" {
+		service.State = StateEnabled
+	}
+	service.RawProtocol = service.Protocol
+	service.Protocol = normalizeProtocol(service.Protocol)
+	checkProtocol(&service)
+	return service, true, nil
+}
+
+// parseServiceLine is a function that does the work of ParseServiceLine.  A line that ends before the port is an error
+// rather than a panic.  Fields may be separated by any run of spaces and tabs.
+func parseServiceLine(serviceLine string, servers *ServerIndex) (Service, bool, error) {
+	serviceLine = strings.TrimSpace(serviceLine)
+	quoteIndex, err := QuoteIndex(serviceLine)
+	if err != nil {
+		return Service{}, false, err
+	}
+	length := len(quoteIndex)
+	if length != 0 { // First quote for service name.
+		intSlice := quoteIndex[0][0]
+		if intSlice == 0 {
+			extractedQuote, err := ExtractQuote(serviceLine)
+			if err != nil {
+				return Service{}, false, err
+			}
+			trimLine := strings.TrimSpace(extractedQuote)
+			removedQuote := RemoveQuote(trimLine)
+			if removedQuote == "" {
+				return Service{}, false, &tokenError{token: trimLine, err: errors.New("empty service name")}
+			}
+			service := Service{HealthMonitor: true}
+			service.Name = removedQuote
+			replaceName := strings.Replace(serviceLine, trimLine, "", 1)
+			trimSpace := strings.TrimSpace(replaceName)
+			quoteIndex, err := QuoteIndex(trimSpace)
+			if err != nil {
+				return Service{}, false, err
+			}
+			length := len(quoteIndex)
+			if length != 0 { // Second quote for server name
+				intSlice := quoteIndex[0][0]
+				if intSlice == 0 {
+					extractedQuote, err := ExtractQuote(trimSpace)
+					if err != nil {
+						return Service{}, false, err
+					}
+					trimLine := strings.TrimSpace(extractedQuote)
+					removedQuote := RemoveQuote(trimLine)
+					serviceServer, err := lookupServer(servers, removedQuote)
+					if err != nil {
+						return Service{}, false, err
+					}
+					service.Server = serviceServer
+					replaceName := strings.Replace(trimSpace, extractedQuote, "", 1)
+					trimSpace := strings.TrimSpace(replaceName)
+					serviceLineArray := splitFields(trimSpace)
+					err = checkServiceFields(service.Name, serviceLineArray, "protocol", "port")
+					if err != nil {
+						return Service{}, false, err
+					}
+					service.Protocol = serviceLineArray[0]
+					service.Port, err = ParsePort(serviceLineArray[1])
+					if err != nil {
+						return Service{}, false, fmt.Errorf("service %s: %w", service.Name, err)
+					}
+					ParseServiceOptions(&service, serviceLineArray)
+					return service, true, nil
+				}
+			}
+			if length == 0 || quoteIndex[0][0] != 0 { // No quote for server name, though an option value may be quoted.
+				serviceLineArray := splitFields(trimSpace)
+				err = checkServiceFields(service.Name, serviceLineArray, "server", "protocol", "port")
+				if err != nil {
+					return Service{}, false, err
+				}
+				service.Server, err = lookupServer(servers, serviceLineArray[0])
+				if err != nil {
+					return Service{}, false, err
+				}
+				service.Protocol = serviceLineArray[1]
+				service.Port, err = ParsePort(serviceLineArray[2])
+				if err != nil {
+					return Service{}, false, fmt.Errorf("service %s: %w", service.Name, err)
+				}
+				ParseServiceOptions(&service, serviceLineArray)
+				return service, true, nil
+			}
+		}
+		if intSlice != 0 { // No quote for service name.
+			extractNoQuote, err := ExtractNoQuote(serviceLine)
+			if err != nil {
+				return Service{}, false, err
+			}
+			trimNoQuote := strings.TrimSpace(extractNoQuote)
+			if trimNoQuote == "" {
+				return Service{}, false, &tokenError{end: true, err: errors.New("missing service name")}
+			}
+			service := Service{HealthMonitor: true}
+			service.Name = trimNoQuote
+			replaceNoQuote := strings.Replace(serviceLine, extractNoQuote, "", 1)
+			trimReplace := strings.TrimSpace(replaceNoQuote)
+			quoteIndex, err := QuoteIndex(trimReplace)
+			if err != nil {
+				return Service{}, false, err
+			}
+			length := len(quoteIndex)
+			if length != 0 { // Quote for server name of service name with no quote.
+				intSlice := quoteIndex[0][0]
+				if intSlice == 0 {
+					extractQuote, err := ExtractQuote(trimReplace)
+					if err != nil {
+						return Service{}, false, err
+					}
+					trimQuote := strings.TrimSpace(extractQuote)
+					removeQuote := RemoveQuote(trimQuote)
+					service.Server, err = lookupServer(servers, removeQuote)
+					if err != nil {
+						return Service{}, false, err
+					}
+					replaceQuote := strings.Replace(trimReplace, extractQuote, "", 1)
+					trimQuote = strings.TrimSpace(replaceQuote)
+					serviceLineArray := splitFields(trimQuote)
+					err = checkServiceFields(service.Name, serviceLineArray, "protocol", "port")
+					if err != nil {
+						return Service{}, false, err
+					}
+					service.Protocol = serviceLineArray[0]
+					service.Port, err = ParsePort(serviceLineArray[1])
+					if err != nil {
+						return Service{}, false, fmt.Errorf("service %s: %w", service.Name, err)
+					}
+					ParseServiceOptions(&service, serviceLineArray)
+					return service, true, nil
+				}
+			}
+			// No quote for server name either, so the quote is in an option value such as a -comment.
+			serviceLineArray := splitFields(trimReplace)
+			err = checkServiceFields(service.Name, serviceLineArray, "server", "protocol", "port")
+			if err != nil {
+				return Service{}, false, err
+			}
+			service.Server, err = lookupServer(servers, serviceLineArray[0])
+			if err != nil {
+				return Service{}, false, err
+			}
+			service.Protocol = serviceLineArray[1]
+			service.Port, err = ParsePort(serviceLineArray[2])
+			if err != nil {
+				return Service{}, false, fmt.Errorf("service %s: %w", service.Name, err)
+			}
+			ParseServiceOptions(&service, serviceLineArray)
+			return service, true, nil
+		}
+	}
+	if length == 0 {
+		// This section is for no quotes detected.
+		trimSpace := strings.TrimSpace(serviceLine)
+		serviceLineArray := splitFields(trimSpace)
+		service := Service{HealthMonitor: true}
+		if len(serviceLineArray) != 0 {
+			service.Name = serviceLineArray[0]
+		}
+		err = checkServiceFields(service.Name, serviceLineArray, "name", "server", "protocol", "port")
+		if err != nil {
+			return Service{}, false, err
+		}
+		service.Server, err = lookupServer(servers, serviceLineArray[1])
+		if err != nil {
+			return Service{}, false, err
+		}
+		service.Protocol = serviceLineArray[2]
+		service.Port, err = ParsePort(serviceLineArray[3])
+		if err != nil {
+			return Service{}, false, fmt.Errorf("service %s: %w", service.Name, err)
+		}
+		ParseServiceOptions(&service, serviceLineArray)
+		return service, true, nil
+	}
+	return Service{}, false, nil
+}
+
+// ParseServices is a function that returns an array of Load Balancing services from the contents of a NetScaler
+// configuration that has already been loaded into memory.  It does no IO of its own, so it can be used with
+// configurations that do not come from a file.
+func ParseServices(file string) ([]Service, error) {
+	return ParseServicesContext(context.Background(), file)
+}
+
+// ParseServicesContext is a function that returns an array of Load Balancing services like ParseServices, but stops
+// and returns ctx.Err() if ctx is cancelled before the parse completes.
+func ParseServicesContext(ctx context.Context, file string) ([]Service, error) {
+	return ParserConfig{}.ParseServices(ctx, file)
+}
+
+// GetServices is a method that returns an array of Load Balancing services from a file, parsed with the options of
+// the ParserConfig.
+func (c ParserConfig) GetServices(ctx context.Context, fileName string) ([]Service, error) {
+	file, err := c.getFile(fileName)
+	if err != nil {
+		return []Service{}, err
+	}
+	return c.ParseServices(ctx, file)
+}
+
+// ParseServices is a method that returns an array of Load Balancing services from the contents of a NetScaler
+// configuration, parsed with the options of the ParserConfig.  ctx is checked before each "add service" line is
+// parsed, and ctx.Err() is returned once it is cancelled.  No more "add service" lines are parsed once the Limit is
+// reached.  A service without a usip value of its own takes the one set by the USIP mode of "enable ns mode" and
+// "disable ns mode" lines, and is marked as USIPInherited.
+func (c ParserConfig) ParseServices(ctx context.Context, file string) ([]Service, error) {
+	file = RemoveBOM(NormalizeLineEndings(file))
+	servers, err := c.parseServerIndex(file)
+	if err != nil {
+		return []Service{}, err
+	}
+	addServiceLines, err := GetConfigLines(file, c.servicePattern())
+	if err != nil {
+		return []Service{}, err
+	}
+	var services []Service
+	for _, addServiceLine := range addServiceLines {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if c.limitReached(len(services)) {
+			break
+		}
+		service, ok, err := c.parseAddServiceLine(addServiceLine, servers)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			services = append(services, service)
+		}
+	}
+	err = applyServiceLines(file, services, newServiceIndexes(services))
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := ParseGlobalDefaults(file)
+	if err != nil {
+		return nil, err
+	}
+	applyGlobalDefaults(services, defaults)
+	c.applyHook(services)
+	logServices(services)
+	return services, nil
+}
+
+// parseAddServiceLine is a method that returns the Service defined by an "add service" line matched from a NetScaler
+// configuration, looking up its server in servers.  The returned bool is false when the line is skipped, either
+// because it is in a form that is not handled or because its server is missing and SkipMissingServers is set.  An
+// error is prefixed with the line number.
+func (c ParserConfig) parseAddServiceLine(addServiceLine ConfigLine, servers *ServerIndex) (Service, bool, error) {
+	serviceLine := RemoveConfigKeywords(addServiceLine.Text, "add service ")
+	service, ok, err := ParseServiceLine(serviceLine, servers)
+	if err != nil && c.SkipMissingServers && errors.Is(err, ErrServerNotFound) {
+		logger.Printf("line %d: skipping service line %q: %v", addServiceLine.Number, addServiceLine.Text, err)
+		return Service{}, false, nil
+	}
+	if err != nil {
+		return Service{}, false, newParseError(addServiceLine, "malformed add service", err)
+	}
+	if !ok {
+		logger.Printf("line %d: skipping service line %q", addServiceLine.Number, addServiceLine.Text)
+		return Service{}, false, nil
+	}
+	setServiceLine(&service, addServiceLine)
+	return service, true, nil
+}
+
+// setServiceLine is a function that records on service the "add service" line it was parsed from, so that it can be
+// traced back to the configuration.
+func setServiceLine(service *Service, addServiceLine ConfigLine) {
+	service.LineNumber = addServiceLine.Number
+	service.RawLine = strings.TrimSpace(addServiceLine.Text)
+}
+
+// logServices is a function that logs the values parsed for each of services.
+func logServices(services []Service) {
+	for _, service := range services {
+		logger.Printf("parsed service %q: server %q, protocol %s, port %s, usip %q", service.Name, service.Server.Name,
+			service.Protocol, FormatPort(service.Port), service.USIP)
+	}
+}
+
+// splitFields is a function that splits line into fields separated by runs of whitespace, as strings.Fields does,
+// except that a quoted or q-delimited string is kept whole, quotes included, however many spaces it contains.  This
+// keeps an option value such as -comment "moved from -usip NO" from being read as options of its own.
+func splitFields(line string) []string {
+	var fields []string
+	quoted := quoteRegexp.FindAllStringIndex(line, -1)
+	start := -1
+	for ix := 0; ix < len(line); {
+		if len(quoted) != 0 && quoted[0][0] == ix {
+			if start == -1 {
+				start = ix
+			}
+			ix = quoted[0][1]
+			quoted = quoted[1:]
+			continue
+		}
+		if unicode.IsSpace(rune(line[ix])) {
+			if start != -1 {
+				fields = append(fields, line[start:ix])
+				start = -1
+			}
+		} else if start == -1 {
+			start = ix
+		}
+		ix++
+	}
+	if start != -1 {
+		fields = append(fields, line[start:])
+	}
+	return fields
+}
+
+// checkServiceFields is a function that returns an error when the fields of a service line, split after any quoted
+// names, do not start with the fields named in names, as happens when the line is truncated.
+func checkServiceFields(serviceName string, fields []string, names ...string) error {
+	for ix, name := range names {
+		if ix >= len(fields) || fields[ix] == "" {
+			return &tokenError{end: true, err: fmt.Errorf("service %s: truncated line: missing %s", serviceName, name)}
+		}
+	}
+	return nil
+}
+
+// ParsePort is a function that converts the port token of a service line into an int.  The NetScaler wildcard port
+// "*" is returned as WildcardPort; any other value must be a number between 1 and 65535.
+func ParsePort(token string) (int, error) {
+	token = strings.TrimSpace(token)
+	if token == "*" {
+		return WildcardPort, nil
+	}
+	port, err := strconv.Atoi(token)
+	if err != nil || port < 1 || port > 65535 {
+		err := fmt.Errorf("invalid port %q: must be a number between 1 and 65535 or *", token)
+		return 0, &tokenError{token: token, err: err}
+	}
+	return port, nil
+}
+
+// ParseServiceOptions is a function that reads the options of interest from the tokens of a service line and stores
+// them on the service.  An option that is the last token on the line has no value and is stored as an empty string.
+// When an option appears more than once on the same line the first occurrence wins and the others are ignored.
+func ParseServiceOptions(service *Service, tokens []string) {
+	seen := make(map[string]bool)
+	for ix, token := range tokens {
+		if repeatedOption(seen, token) {
+			continue
+		}
+		switch token {
+		case "-usip":
+			service.USIP = optionValue(tokens, ix)
+		case "-cip":
+			service.CIP = optionValue(tokens, ix) == "ENABLED"
+			// The header may follow the state directly instead of being given with -cipHeader.
+			header := optionValue(tokens, ix+1)
+			if service.CIP && header != "" && !strings.HasPrefix(header, "-") {
+				service.CIPHeader = header
+			}
+		case "-cipHeader":
+			service.CIPHeader = optionValue(tokens, ix)
+		case "-state":
+			service.State = strings.ToUpper(optionValue(tokens, ix))
+		case "-maxClient":
+			service.MaxClient = parseLimit(service, "maxClient", optionValue(tokens, ix))
+		case "-maxReq":
+			service.MaxReq = parseLimit(service, "maxReq", optionValue(tokens, ix))
+		case "-cltTimeout":
+			service.CltTimeout = parseLimit(service, "cltTimeout", optionValue(tokens, ix))
+		case "-svrTimeout":
+			service.SvrTimeout = parseLimit(service, "svrTimeout", optionValue(tokens, ix))
+		case "-healthMonitor":
+			service.HealthMonitor = strings.ToUpper(optionValue(tokens, ix)) != "NO"
+		}
+	}
+}
+
+// parseLimit is a function that converts the value of a numeric limit option, such as -maxClient or -cltTimeout, into
+// an int.  A value that is not a non-negative number is treated as no limit and adds a ParseWarning to service.
+func parseLimit(service *Service, field, value string) int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		service.Warnings = append(service.Warnings, ParseWarning{Field: field, Value: value, Reason: "invalid limit"})
+		return 0
+	}
+	return limit
+}
+
+// repeatedOption is a function that reports whether token is an option that has already been seen on the line, and
+// records it as seen otherwise.  Tokens that are not options are never repeated.
+func repeatedOption(seen map[string]bool, token string) bool {
+	if !strings.HasPrefix(token, "-") {
+		return false
+	}
+	if seen[token] {
+		logger.Printf("ignoring repeated option %s", token)
+		return true
+	}
+	seen[token] = true
+	return false
+}
+
+// optionValue is a function that returns the token following the option at index ix, or an empty string if the
+// option is the last token.
+func optionValue(tokens []string, ix int) string {
+	if ix+1 >= len(tokens) {
+		return ""
+	}
+	return tokens[ix+1]
+}
+
+// ApplyMonitorBindings is a function that records on each service the names of the monitors bound to it by the
+// "bind service <name> -monitorName <monitor>" lines within the contents of a file.  "unbind service" lines remove the
+// monitor again, in the same pass, so that the last of the two in the file wins.
+func ApplyMonitorBindings(file string, services []Service) error {
+	return applyMonitorBindings(file, services, newServiceIndexes(services))
+}
+
+// applyMonitorBindings is a function that applies the "bind service" lines within the contents of a file as
+// ApplyMonitorBindings does, finding the services by name through indexes.
+func applyMonitorBindings(file string, services []Service, indexes serviceIndexes) error {
+	for _, bindServiceLine := range findConfigLines(file, bindServiceRegexp) {
+		line := strings.TrimSpace(bindServiceLine.Text)
+		unbind := strings.HasPrefix(line, "un")
+		serviceLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "bind service ")
+		name, options, err := ExtractName(serviceLine)
+		if err != nil {
+			return newParseError(bindServiceLine, "malformed bind service", err)
+		}
+		optionArray := strings.Fields(options)
+		for ox, option := range optionArray {
+			if option != "-monitorName" {
+				continue
+			}
+			monitor := optionValue(optionArray, ox)
+			if monitor == "" {
+				continue
+			}
+			for _, ix := range indexes[name] {
+				if unbind {
+					services[ix].Monitors = removeName(services[ix].Monitors, monitor)
+				} else {
+					services[ix].Monitors = append(services[ix].Monitors, monitor)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyCertKeyBindings is a function that records on each service the certificate bound to it by the
+// "bind ssl service <name> -certkeyName <cert>" lines within the contents of a file.  CA certificate bindings, marked
+// with -CA, are not recorded.  Only SSL services are expected to have a certificate, so a binding to any other service
+// adds a ParseWarning to it.  An "unbind ssl service" line removes the certificate again if it is the one bound.
+func ApplyCertKeyBindings(file string, services []Service) error {
+	return applyCertKeyBindings(file, services, newServiceIndexes(services))
+}
+
+// applyCertKeyBindings is a function that applies the "bind ssl service" lines within the contents of a file as
+// ApplyCertKeyBindings does, finding the services by name through indexes.
+func applyCertKeyBindings(file string, services []Service, indexes serviceIndexes) error {
+	for _, bindSSLServiceLine := range findConfigLines(file, bindSSLServiceRegexp) {
+		line := strings.TrimSpace(bindSSLServiceLine.Text)
+		unbind := strings.HasPrefix(line, "un")
+		serviceLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "bind ssl service ")
+		name, options, err := ExtractName(serviceLine)
+		if err != nil {
+			return newParseError(bindSSLServiceLine, "malformed bind ssl service", err)
+		}
+		var certKey string
+		var ca bool
+		optionArray := strings.Fields(options)
+		for ox, option := range optionArray {
+			switch option {
+			case "-certkeyName":
+				certKey = optionValue(optionArray, ox)
+			case "-CA":
+				ca = true
+			}
+		}
+		if certKey == "" || ca {
+			continue
+		}
+		for _, ix := range indexes[name] {
+			if unbind {
+				if services[ix].CertKey == certKey {
+					services[ix].CertKey = ""
+				}
+				continue
+			}
+			services[ix].CertKey = certKey
+			if !IsSSLProtocol(services[ix].Protocol) {
+				services[ix].Warnings = append(services[ix].Warnings, ParseWarning{
+					Field:  "certKey",
+					Value:  certKey,
+					Reason: "certificate bound to a non-SSL service",
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// ExtractName is a function that splits a line into the leading name, which may or may not be surrounded by quotes,
+// and the remainder of the line.  The returned name has its quotes removed.  A line without a name, or with an empty
+// quoted name, is an error.
+func ExtractName(line string) (string, string, error) {
+	quoteIndex, err := QuoteIndex(line)
+	if err != nil {
+		return "", "", err
+	}
+	if len(quoteIndex) != 0 && quoteIndex[0][0] == 0 {
+		extractedQuote, err := ExtractQuote(line)
+		if err != nil {
+			return "", "", err
+		}
+		name := RemoveQuote(strings.TrimSpace(extractedQuote))
+		if name == "" {
+			return "", "", &tokenError{token: extractedQuote, err: errors.New("empty name")}
+		}
+		remainder := strings.Replace(line, extractedQuote, "", 1)
+		return name, strings.TrimSpace(remainder), nil
+	}
+	extractNoQuote, err := ExtractNoQuote(line)
+	if err != nil {
+		return "", "", err
+	}
+	if extractNoQuote == "" {
+		// The name is the only token on the line.
+		name := strings.TrimSpace(line)
+		if name == "" {
+			return "", "", &tokenError{end: true, err: errors.New("missing name")}
+		}
+		return name, "", nil
+	}
+	remainder := strings.Replace(line, extractNoQuote, "", 1)
+	return strings.TrimSpace(extractNoQuote), strings.TrimSpace(remainder), nil
+}
+
+// The regular expressions for the lines applied by applyServiceLines are compiled once, rather than for every file or,
+// when scanning, for every line.
+var (
+	setServiceRegexp     = regexp.MustCompile(`(?m)^[ \t]*(?:un)?set[ \t]+service[ \t].*`)
+	bindServiceRegexp    = regexp.MustCompile(`(?m)^[ \t]*(?:un)?bind[ \t]+service[ \t].*`)
+	bindSSLServiceRegexp = regexp.MustCompile(`(?m)^[ \t]*(?:un)?bind[ \t]+ssl[ \t]+service[ \t].*`)
+)
+
+// serviceIndexes is a data structure that maps the name of a service to its positions within an array of services.
+// The members of a service group share its name, so a name may have more than one position.
+type serviceIndexes map[string][]int
+
+// newServiceIndexes is a function that returns the serviceIndexes of services.
+func newServiceIndexes(services []Service) serviceIndexes {
+	indexes := make(serviceIndexes, len(services))
+	for ix, service := range services {
+		indexes.add(service.Name, ix)
+	}
+	return indexes
+}
+
+// add is a method that records a service with the given name at position ix.
+func (indexes serviceIndexes) add(name string, ix int) {
+	indexes[name] = append(indexes[name], ix)
+}
+
+// applyServiceLines is a function that applies the lines that change or bind to services after they are added, such
+// as "set service" and "bind service", onto services.  The services are found by name through indexes, which must
+// match services.
+func applyServiceLines(file string, services []Service, indexes serviceIndexes) error {
+	appliers := []func(string, []Service, serviceIndexes) error{
+		applyServiceOverrides,
+		applyVServerBindings,
+		applyMonitorBindings,
+		applyCertKeyBindings,
+	}
+	for _, apply := range appliers {
+		if err := apply(file, services, indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyServiceOverrides is a function that applies the options from any "set service" lines within the contents of a
+// file onto the previously parsed services with the same name.  NetScaler configurations commonly add a service with
+// default options and change them later in the file, so the "set service" values take precedence.  "unset service"
+// lines are applied in the same pass, so that the last of the two in the file wins.
+func ApplyServiceOverrides(file string, services []Service) error {
+	return applyServiceOverrides(file, services, newServiceIndexes(services))
+}
+
+// applyServiceOverrides is a function that applies the "set service" lines within the contents of a file as
+// ApplyServiceOverrides does, finding the services by name through indexes.
+func applyServiceOverrides(file string, services []Service, indexes serviceIndexes) error {
+	for _, setServiceLine := range findConfigLines(file, setServiceRegexp) {
+		line := strings.TrimSpace(setServiceLine.Text)
+		unset := strings.HasPrefix(line, "un")
+		serviceLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "set service ")
+		name, options, err := ExtractName(serviceLine)
+		if err != nil {
+			return newParseError(setServiceLine, "malformed set service", err)
+		}
+		optionArray := splitFields(options)
+		for _, ix := range indexes[name] {
+			if unset {
+				UnsetServiceOptions(&services[ix], optionArray)
+			} else {
+				ParseServiceOptions(&services[ix], optionArray)
+			}
+		}
+	}
+	return nil
+}
+
+// UnsetServiceOptions is a function that returns the options of interest named by the tokens of an "unset service"
+// line to their defaults.  An unset usip option is stored as an empty string, as for a service added without one.
+func UnsetServiceOptions(service *Service, tokens []string) {
+	for _, token := range tokens {
+		switch token {
+		case "-usip":
+			service.USIP = ""
+		case "-cip":
+			service.CIP = false
+		case "-cipHeader":
+			service.CIPHeader = ""
+		case "-maxClient":
+			service.MaxClient = 0
+		case "-maxReq":
+			service.MaxReq = 0
+		case "-cltTimeout":
+			service.CltTimeout = 0
+		case "-svrTimeout":
+			service.SvrTimeout = 0
+		case "-healthMonitor":
+			service.HealthMonitor = true
+		}
+	}
+}
+
+// FindService is a function that accepts a file name as a parameter as well as service name as a string and returns
+// a single Service type.  Only the matching "add service" line is parsed, and its server is resolved through a
+// ServerIndex of the file.  The service is returned as GetServices returns it, taking the global usip value when it has
+// none of its own.
+func FindService(fileName, serviceName string) (Service, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return Service{}, err
+	}
+	servers, err := ParserConfig{}.parseServerIndex(file)
+	if err != nil {
+		return Service{}, err
+	}
+	addServiceLines, err := GetConfigLines(file, DefaultServicePattern)
+	if err != nil {
+		return Service{}, err
+	}
+	for _, addServiceLine := range addServiceLines {
+		serviceLine := RemoveConfigKeywords(addServiceLine.Text, "add service ")
+		name, _, err := ExtractName(serviceLine)
+		if err != nil {
+			return Service{}, newParseError(addServiceLine, "malformed add service", err)
+		}
+		if name != serviceName {
+			continue
+		}
+		service, ok, err := ParseServiceLine(serviceLine, servers)
+		if err != nil {
+			return Service{}, newParseError(addServiceLine, "malformed add service", err)
+		}
+		if !ok {
+			continue
+		}
+		setServiceLine(&service, addServiceLine)
+		services := []Service{service}
+		err = applyServiceLines(file, services, newServiceIndexes(services))
+		if err != nil {
+			return Service{}, err
+		}
+		defaults, err := ParseGlobalDefaults(file)
+		if err != nil {
+			return Service{}, err
+		}
+		applyGlobalDefaults(services, defaults)
+		return services[0], nil
+	}
+	return Service{}, fmt.Errorf("service not found: %s", serviceName)
+}
+
+// GetAllServices is a function that returns the Load Balancing services of a file followed by one service for each
+// service group member.  Every service is tagged with the file it was read from.
+func GetAllServices(fileName string) ([]Service, error) {
+	return ParserConfig{}.GetAllServices(context.Background(), fileName)
+}
+
+// GetAllServices is a method that returns the Load Balancing services and service group members from a file, parsed
+// with the options of the ParserConfig, as the GetAllServices function does.
+func (c ParserConfig) GetAllServices(ctx context.Context, fileName string) ([]Service, error) {
+	file, err := c.getFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	services, err := c.ParseAllServices(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	for ix := range services {
+		services[ix].SourceFile = fileName
+	}
+	return services, nil
+}
+
+// ParseAllServices is a function that returns the Load Balancing services and service group members from the
+// contents of a NetScaler configuration.
+func ParseAllServices(file string) ([]Service, error) {
+	return ParserConfig{}.ParseAllServices(context.Background(), file)
+}
+
+// ParseAllServices is a method that returns the Load Balancing services and service group members from the contents
+// of a NetScaler configuration, parsed with the options of the ParserConfig.  When the Limit is reached by the
+// services the service groups are not parsed, and otherwise only as many members are added as it allows.
+func (c ParserConfig) ParseAllServices(ctx context.Context, file string) ([]Service, error) {
+	services, err := c.ParseServices(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if c.limitReached(len(services)) {
+		return services, nil
+	}
+	serviceGroups, err := c.parseServiceGroups(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, serviceGroup := range serviceGroups {
+		services = append(services, serviceGroup.Services()...)
+	}
+	if c.limitReached(len(services)) {
+		services = services[:c.Limit]
+	}
+	return services, nil
+}
+
+// ParseAll is a function that accepts a file name as a parameter and returns the complete parsed configuration: its
+// servers, services, service groups and virtual servers.  The file is read once, so every part of the configuration
+// comes from the same contents.  Each part is sorted by name so that the result is stable across runs, and a part with
+// nothing in it is an empty slice rather than nil.
+func ParseAll(fileName string) (*Config, error) {
+	return ParserConfig{}.ParseAll(fileName)
+}
+
+// ParseAll is a method that returns the complete parsed configuration of a file, as the ParseAll function does, once
+// it is decoded from the Encoding.
+func (c ParserConfig) ParseAll(fileName string) (*Config, error) {
+	file, err := c.getFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(file)
+}
+
+// ParseConfig is a function that returns the complete parsed configuration from the contents of a NetScaler
+// configuration, as ParseAll does for a file.
+func ParseConfig(file string) (*Config, error) {
+	file = NormalizeLineEndings(file)
+	servers, err := ParserConfig{}.parseServers(file)
+	if err != nil {
+		return nil, err
+	}
+	services, err := ParseServices(file)
+	if err != nil {
+		return nil, err
+	}
+	serviceGroups, err := ParserConfig{}.parseServiceGroups(file)
+	if err != nil {
+		return nil, err
+	}
+	vservers, err := parseVServers(file, services)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := ParseGlobalDefaults(file)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	sort.SliceStable(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	sort.SliceStable(serviceGroups, func(i, j int) bool { return serviceGroups[i].Name < serviceGroups[j].Name })
+	sort.SliceStable(vservers, func(i, j int) bool { return vservers[i].Name < vservers[j].Name })
+	if servers == nil {
+		servers = []Server{}
+	}
+	if services == nil {
+		services = []Service{}
+	}
+	if serviceGroups == nil {
+		serviceGroups = []ServiceGroup{}
+	}
+	if vservers == nil {
+		vservers = []VServer{}
+	}
+	return &Config{Servers: servers, Services: services, ServiceGroups: serviceGroups, VServers: vservers,
+		Defaults: defaults}, nil
+}
+
+// BuildConfig is a function that accepts a file name as a parameter and returns the complete parsed configuration,
+// as ParseAll does.
+func BuildConfig(fileName string) (Config, error) {
+	config, err := ParseAll(fileName)
+	if err != nil {
+		return Config{}, err
+	}
+	return *config, nil
+}
@@ -0,0 +1,1078 @@
+package netscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fixture is a function that returns the path of the named file in testdata.
+func fixture(name string) string {
+	return filepath.Join("testdata", name)
+}
+
+// readFixture is a function that returns the contents of the named file in testdata, failing the test on an error.
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	file, err := GetFile(fixture(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// getServices is a function that returns the services of the named file in testdata, failing the test on an error.
+func getServices(t *testing.T, name string) []Service {
+	t.Helper()
+	services, err := GetServices(fixture(name))
+	if err != nil {
+		t.Fatalf("GetServices(%q) error = %v", name, err)
+	}
+	return services
+}
+
+// servicesByName is a function that returns the services of the named file in testdata by name.
+func servicesByName(t *testing.T, name string) map[string]Service {
+	t.Helper()
+	services := make(map[string]Service)
+	for _, service := range getServices(t, name) {
+		services[service.Name] = service
+	}
+	return services
+}
+
+func TestGetServicesTruncatedUSIP(t *testing.T) {
+	services := getServices(t, "truncated_usip.conf")
+	if len(services) != 2 {
+		t.Fatalf("GetServices() returned %d services, want 2", len(services))
+	}
+	for _, service := range services {
+		if service.USIP != "" {
+			t.Errorf("service %q: USIP = %q, want empty", service.Name, service.USIP)
+		}
+	}
+}
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    int
+		wantErr bool
+	}{
+		{token: "443", want: 443},
+		{token: "*", want: WildcardPort},
+		{token: "65535", want: 65535},
+		{token: "abc", wantErr: true},
+		{token: "0", wantErr: true},
+		{token: "65536", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePort(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePort(%q) error = %v, wantErr %v", tt.token, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePort(%q) = %d, want %d", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestParseServicesPort(t *testing.T) {
+	services, err := ParseServices("add server web1 10.0.0.1\nadd service svc1 web1 SSL 443\nadd service svc2 web1 ANY *\n")
+	if err != nil {
+		t.Fatalf("ParseServices() error = %v", err)
+	}
+	if len(services) != 2 || services[0].Port != 443 || services[1].Port != WildcardPort {
+		t.Errorf("ParseServices() = %+v, want ports 443 and %d", services, WildcardPort)
+	}
+	if _, err := ParseServices("add server web1 10.0.0.1\nadd service svc1 web1 HTTP abc\n"); err == nil {
+		t.Error("ParseServices() with port abc: error = nil, want an error")
+	}
+}
+
+func TestGetServersAddressFamily(t *testing.T) {
+	servers, err := GetServers(fixture("servers.conf"))
+	if err != nil {
+		t.Fatalf("GetServers() error = %v", err)
+	}
+	want := map[string]AddressFamily{
+		"web4":   AddressFamilyIPv4,
+		"web6":   AddressFamilyIPv6,
+		"webdns": AddressFamilyNone,
+		"web 6b": AddressFamilyIPv6,
+	}
+	if len(servers) != len(want) {
+		t.Fatalf("GetServers() returned %d servers, want %d", len(servers), len(want))
+	}
+	for _, server := range servers {
+		if got := server.AddressFamily(); got != want[server.Name] {
+			t.Errorf("server %q: AddressFamily() = %q, want %q", server.Name, got, want[server.Name])
+		}
+	}
+	if servers[1].IPAddress != "2001:db8::1" {
+		t.Errorf("server web6: IPAddress = %q, want 2001:db8::1", servers[1].IPAddress)
+	}
+}
+
+func TestGetServersDomain(t *testing.T) {
+	servers, err := GetServers(fixture("servers.conf"))
+	if err != nil {
+		t.Fatalf("GetServers() error = %v", err)
+	}
+	want := []Server{
+		{Name: "web4", IPAddress: "10.0.0.1"},
+		{Name: "web6", IPAddress: "2001:db8::1"},
+		{Name: "webdns", Domain: "web.example.com"},
+		{Name: "web 6b", IPAddress: "2001:db8::2", Comment: "second v6"},
+	}
+	if len(servers) != len(want) {
+		t.Fatalf("GetServers() = %+v, want %+v", servers, want)
+	}
+	for ix := range want {
+		if servers[ix] != want[ix] {
+			t.Errorf("GetServers()[%d] = %+v, want %+v", ix, servers[ix], want[ix])
+		}
+	}
+}
+
+func TestExtractQuoteEscaped(t *testing.T) {
+	line := `"svc\"weird\"name" web1 HTTP 80`
+	quoted, err := ExtractQuote(line)
+	if err != nil {
+		t.Fatalf("ExtractQuote() error = %v", err)
+	}
+	if want := `"svc\"weird\"name"`; quoted != want {
+		t.Errorf("ExtractQuote(%q) = %q, want %q", line, quoted, want)
+	}
+	if got, want := RemoveQuote(quoted), `svc"weird"name`; got != want {
+		t.Errorf("RemoveQuote(%q) = %q, want %q", quoted, got, want)
+	}
+	quoteIndex, err := QuoteIndex(line)
+	if err != nil {
+		t.Fatalf("QuoteIndex() error = %v", err)
+	}
+	if len(quoteIndex) != 1 || quoteIndex[0][0] != 0 || quoteIndex[0][1] != len(quoted) {
+		t.Errorf("QuoteIndex(%q) = %v, want [[0 %d]]", line, quoteIndex, len(quoted))
+	}
+}
+
+func TestGetServicesEscapedQuotes(t *testing.T) {
+	services := getServices(t, "escaped_quotes.conf")
+	if len(services) != 1 {
+		t.Fatalf("GetServices() returned %d services, want 1", len(services))
+	}
+	service := services[0]
+	if service.Name != `svc"weird"name` || service.Server.IPAddress != "10.0.0.1" || service.Port != 80 {
+		t.Errorf("GetServices()[0] = %+v, want svc\"weird\"name on 10.0.0.1 port 80", service)
+	}
+	if want := `the "main" web server`; service.Server.Comment != want {
+		t.Errorf("server comment = %q, want %q", service.Server.Comment, want)
+	}
+}
+
+// generateConfig is a function that returns a configuration with the given numbers of servers and services, the
+// services spread evenly over the servers and every other one using USIP.
+func generateConfig(servers, services int) string {
+	var config strings.Builder
+	for ix := 0; ix < servers; ix++ {
+		fmt.Fprintf(&config, "add server web%d 10.%d.%d.%d\n", ix, ix/65536%256, ix/256%256, ix%256)
+	}
+	for ix := 0; ix < services; ix++ {
+		usip := "NO"
+		if ix%2 == 0 {
+			usip = "YES"
+		}
+		fmt.Fprintf(&config, "add service svc%d web%d HTTP %d -usip %s\n", ix, ix%servers, 80+ix%1000, usip)
+	}
+	return config.String()
+}
+
+func BenchmarkParseServices(b *testing.B) {
+	config := generateConfig(1000, 2000)
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := ParseServices(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseServicesBindings parses a configuration with a "set service" and two bind lines for every service,
+// so that applying them costs as much as adding the services.
+func BenchmarkParseServicesBindings(b *testing.B) {
+	var config strings.Builder
+	config.WriteString(generateConfig(1000, 2000))
+	for ix := 0; ix < 2000; ix++ {
+		fmt.Fprintf(&config, "set service svc%d -maxClient 5\n", ix)
+		fmt.Fprintf(&config, "bind service svc%d -monitorName ping\n", ix)
+		fmt.Fprintf(&config, "bind lb vserver vs1 svc%d\n", ix)
+	}
+	file := config.String()
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := ParseServices(file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// quoteBenchmarkLine is the line the quote function benchmarks search: a service whose name contains a space.
+const quoteBenchmarkLine = `"web service 1" web1 HTTP 80 -usip YES`
+
+func BenchmarkQuoteIndex(b *testing.B) {
+	b.ReportAllocs()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := QuoteIndex(quoteBenchmarkLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractQuote(b *testing.B) {
+	b.ReportAllocs()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := ExtractQuote(quoteBenchmarkLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractNoQuote(b *testing.B) {
+	b.ReportAllocs()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := ExtractNoQuote(quoteBenchmarkLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkExtractQuoteCompiled compiles the quote pattern on every call, as ExtractQuote once did, for comparison
+// with BenchmarkExtractQuote.
+func BenchmarkExtractQuoteCompiled(b *testing.B) {
+	b.ReportAllocs()
+	for ix := 0; ix < b.N; ix++ {
+		re, err := regexp.Compile(quotePattern + "|" + qDelimitedPattern())
+		if err != nil {
+			b.Fatal(err)
+		}
+		re.FindString(quoteBenchmarkLine)
+	}
+}
+
+func BenchmarkParseServicesParallel(b *testing.B) {
+	config := generateConfig(1000, 2000)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := ParseServices(config); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParseServicesLarge(b *testing.B) {
+	config := generateConfig(5000, 10000)
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := ParseServices(config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerIndexLookup(b *testing.B) {
+	index, err := ParserConfig{}.parseServerIndex(generateConfig(5000, 0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		if _, ok := index.Lookup(fmt.Sprintf("web%d", ix%5000)); !ok {
+			b.Fatal("server not found")
+		}
+	}
+}
+
+func TestGetServicesCRLF(t *testing.T) {
+	services := getServices(t, "crlf.conf")
+	want := getServices(t, "servers_services.conf")
+	if len(services) != len(want) {
+		t.Fatalf("GetServices() returned %d services, want %d", len(services), len(want))
+	}
+	for ix := range want {
+		got := services[ix]
+		if got.Name != want[ix].Name || got.Server != want[ix].Server || got.Protocol != want[ix].Protocol ||
+			got.Port != want[ix].Port || got.USIP != want[ix].USIP {
+			t.Errorf("GetServices()[%d] = %+v, want %+v", ix, got, want[ix])
+		}
+	}
+	if !services[0].HasUSIP() {
+		t.Errorf("service %q: HasUSIP() = false, want true", services[0].Name)
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	if got, want := NormalizeLineEndings("a\r\nb\rc\n"), "a\nb\nc\n"; got != want {
+		t.Errorf("NormalizeLineEndings() = %q, want %q", got, want)
+	}
+}
+
+func TestGetServicesCIP(t *testing.T) {
+	services := servicesByName(t, "cip.conf")
+	tests := []struct {
+		name      string
+		cip       bool
+		cipHeader string
+	}{
+		{name: "svc1", cip: true, cipHeader: "X-Forwarded-For"},
+		{name: "svc2"},
+		{name: "svc 3", cip: true, cipHeader: "X-Real-IP"},
+		{name: "svc4"},
+		{name: "svc 5", cip: true},
+	}
+	for _, tt := range tests {
+		service, ok := services[tt.name]
+		if !ok {
+			t.Errorf("service %q not parsed", tt.name)
+			continue
+		}
+		if service.CIP != tt.cip || service.CIPHeader != tt.cipHeader {
+			t.Errorf("service %q: CIP = %v, CIPHeader = %q, want %v and %q", tt.name, service.CIP, service.CIPHeader,
+				tt.cip, tt.cipHeader)
+		}
+	}
+}
+
+func TestExtractAddress(t *testing.T) {
+	tests := []struct {
+		remainder string
+		want      string
+	}{
+		{remainder: "10.0.0.1", want: "10.0.0.1"},
+		{remainder: `10.0.0.1 -comment "moved from 10.9.9.9"`, want: "10.0.0.1"},
+		{remainder: `-comment "moved from 10.9.9.9" 10.0.0.1`, want: "10.0.0.1"},
+		{remainder: "web.example.com -translationIp 10.1.1.1", want: "web.example.com"},
+		{remainder: "-translationIp 10.1.1.1 web.example.com", want: "web.example.com"},
+		{remainder: "10.0.0.5 #legacy 10.0.0.6", want: "10.0.0.5"},
+		{remainder: "-state DISABLED", want: ""},
+		{remainder: "", want: ""},
+	}
+	for _, tt := range tests {
+		got, err := ExtractAddress(tt.remainder)
+		if err != nil {
+			t.Errorf("ExtractAddress(%q) error = %v", tt.remainder, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExtractAddress(%q) = %q, want %q", tt.remainder, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesServerComments(t *testing.T) {
+	services := servicesByName(t, "server_comments.conf")
+	want := map[string]string{"svc1": "10.0.0.1", "svc2": "web.example.com", "svc3": "10.0.0.3"}
+	for name, address := range want {
+		if got := services[name].Server.Address(); got != address {
+			t.Errorf("service %q: server address = %q, want %q", name, got, address)
+		}
+	}
+}
+
+func TestGetServicesServerComment(t *testing.T) {
+	services := servicesByName(t, "commented_server.conf")
+	want := map[string]string{"svc1": `rack 4, "blue" cabinet`, "svc2": `spare "cold" standby`, "svc3": ""}
+	for name, comment := range want {
+		if got := services[name].Server.Comment; got != comment {
+			t.Errorf("service %q: server comment = %q, want %q", name, got, comment)
+		}
+	}
+	var w strings.Builder
+	if err := WriteJSONL(&w, []Service{services["svc1"]}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"comment":"rack 4, \"blue\" cabinet"`; !strings.Contains(w.String(), want) {
+		t.Errorf("WriteJSONL() wrote %q, want it to contain %q", w.String(), want)
+	}
+}
+
+func TestFindService(t *testing.T) {
+	service, err := FindService(fixture("servers_services.conf"), "svc2")
+	if err != nil {
+		t.Fatalf("FindService(svc2) error = %v", err)
+	}
+	if service.Name != "svc2" || service.Server.IPAddress != "10.0.0.1" || service.Port != 443 || service.USIP != "NO" {
+		t.Errorf("FindService(svc2) = %+v, want svc2 on 10.0.0.1 port 443 with usip NO", service)
+	}
+	_, err = FindService(fixture("servers_services.conf"), "missing")
+	if err == nil || !strings.Contains(err.Error(), "service not found") {
+		t.Errorf("FindService(missing) error = %v, want a service not found error", err)
+	}
+}
+
+func TestApplyMonitorBindings(t *testing.T) {
+	services := servicesByName(t, "monitors.conf")
+	want := map[string][]string{
+		"svc0": nil,
+		"svc1": {"ping"},
+		"svc2": {"ping", "http-ecv"},
+		"svc3": {"tcp"},
+	}
+	for name, monitors := range want {
+		if got := services[name].Monitors; !equalNames(got, monitors) {
+			t.Errorf("service %q: Monitors = %v, want %v", name, got, monitors)
+		}
+	}
+}
+
+func TestGetServicesContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GetServicesContext(ctx, fixture("servers_services.conf")); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetServicesContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := GetServicesContext(context.Background(), fixture("servers_services.conf")); err != nil {
+		t.Errorf("GetServicesContext() error = %v", err)
+	}
+}
+
+func TestGetServicesGzip(t *testing.T) {
+	services := getServices(t, "servers_services.conf.gz")
+	want := getServices(t, "servers_services.conf")
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("GetServices() of the gzipped fixture = %+v, want %+v", services, want)
+	}
+}
+
+func TestServiceHasUSIP(t *testing.T) {
+	tests := []struct {
+		usip string
+		want bool
+	}{
+		{usip: "YES", want: true},
+		{usip: "Yes", want: true},
+		{usip: "YES ", want: true},
+		{usip: " yes", want: true},
+		{usip: "NO", want: false},
+		{usip: "", want: false},
+	}
+	for _, tt := range tests {
+		service := Service{USIP: tt.usip}
+		if got := service.HasUSIP(); got != tt.want {
+			t.Errorf("Service{USIP: %q}.HasUSIP() = %v, want %v", tt.usip, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCertKeyBindings(t *testing.T) {
+	services := servicesByName(t, "certkeys.conf")
+	tests := []struct {
+		name     string
+		certKey  string
+		warnings int
+	}{
+		{name: "ssl1", certKey: "web-cert"},
+		{name: "http1"},
+		{name: "http2", certKey: "stray-cert", warnings: 1},
+		{name: "ssl2"},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.CertKey != tt.certKey || len(service.Warnings) != tt.warnings {
+			t.Errorf("service %q: CertKey = %q, Warnings = %v, want %q and %d warnings", tt.name, service.CertKey,
+				service.Warnings, tt.certKey, tt.warnings)
+		}
+	}
+}
+
+func TestBuildServerNotFound(t *testing.T) {
+	server, err := BuildServer(fixture("servers_services.conf"), "web2")
+	if err != nil || server.IPAddress != "10.0.0.2" {
+		t.Errorf("BuildServer(web2) = %+v, %v, want 10.0.0.2", server, err)
+	}
+	_, err = BuildServer(fixture("servers_services.conf"), "missing")
+	if !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("BuildServer(missing) error = %v, want %v", err, ErrServerNotFound)
+	}
+	_, err = GetServices(fixture("missing_server.conf"))
+	if !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("GetServices() error = %v, want %v", err, ErrServerNotFound)
+	}
+}
+
+func TestNormalizeUSIP(t *testing.T) {
+	tests := []struct {
+		usip string
+		want string
+	}{
+		{usip: "YES", want: "YES"},
+		{usip: "ENABLED", want: "YES"},
+		{usip: "enabled ", want: "YES"},
+		{usip: "NO", want: "NO"},
+		{usip: "DISABLED", want: "NO"},
+		{usip: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeUSIP(tt.usip); got != tt.want {
+			t.Errorf("normalizeUSIP(%q) = %q, want %q", tt.usip, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesUSIPSpellings(t *testing.T) {
+	services := servicesByName(t, "usip_spellings.conf")
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "yes", want: true},
+		{name: "enabled", want: true},
+		{name: "no", want: false},
+		{name: "disabled", want: false},
+		{name: "missing", want: false},
+	}
+	for _, tt := range tests {
+		service, ok := services[tt.name]
+		if !ok {
+			t.Errorf("GetServices() has no service %q", tt.name)
+			continue
+		}
+		if got := service.HasUSIP(); got != tt.want {
+			t.Errorf("service %q HasUSIP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesRepeatedUSIP(t *testing.T) {
+	services := servicesByName(t, "repeated_usip.conf")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: "YES"},
+		{name: "quoted name", want: "YES"},
+		{name: "commented", want: "YES"},
+		{name: "reversed", want: "NO"},
+	}
+	for _, tt := range tests {
+		if got := services[tt.name].USIP; got != tt.want {
+			t.Errorf("service %q USIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if !services["plain"].CIP {
+		t.Errorf("service %q CIP = false, want true", "plain")
+	}
+	serviceGroups, err := GetServiceGroups(fixture("repeated_usip.conf"))
+	if err != nil {
+		t.Fatalf("GetServiceGroups() error = %v", err)
+	}
+	if len(serviceGroups) != 1 || serviceGroups[0].USIP != "YES" {
+		t.Errorf("GetServiceGroups() = %+v, want sg1 with usip YES", serviceGroups)
+	}
+}
+
+func TestGetServicesState(t *testing.T) {
+	services := servicesByName(t, "states.conf")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "enabled", want: StateEnabled},
+		{name: "disabled", want: StateDisabled},
+		{name: "unspecified", want: StateEnabled},
+	}
+	for _, tt := range tests {
+		if got := services[tt.name].State; got != tt.want {
+			t.Errorf("service %q State = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServiceConfigLineRoundTrip(t *testing.T) {
+	const servers = "add server web1 10.0.0.1\nadd server \"web server 2\" 10.0.0.2\n"
+	lines := []string{
+		"add service svc1 web1 HTTP 80",
+		"add service svc2 web1 SSL 443 -usip YES",
+		`add service "my service" "web server 2" HTTP 8080 -usip NO`,
+		`add service "say \"hi\"" web1 TCP * -cip ENABLED -cipHeader X-Forwarded-For`,
+		"add service svc5 web1 HTTP 80 -maxClient 100 -maxReq 10 -cltTimeout 180 -svrTimeout 360",
+		"add service svc6 web1 HTTP 80 -healthMonitor NO -state DISABLED",
+	}
+	parse := func(line string) Service {
+		t.Helper()
+		services, err := ParseServices(servers + line + "\n")
+		if err != nil {
+			t.Fatalf("ParseServices(%q) error = %v", line, err)
+		}
+		if len(services) != 1 {
+			t.Fatalf("ParseServices(%q) returned %d services, want 1", line, len(services))
+		}
+		service := services[0]
+		service.RawLine = ""
+		return service
+	}
+	for _, line := range lines {
+		service := parse(line)
+		configLine := service.ConfigLine()
+		if got := parse(configLine); !reflect.DeepEqual(got, service) {
+			t.Errorf("ConfigLine() of %q = %q, which parses to %+v, want %+v", line, configLine, got, service)
+		}
+	}
+}
+
+func TestValidateServers(t *testing.T) {
+	warnings, err := ValidateServers(fixture("conflicting_servers.conf"))
+	if err != nil {
+		t.Fatalf("ValidateServers() error = %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("ValidateServers() = %+v, want one warning", warnings)
+	}
+	if warnings[0].Value != "web1" || !strings.Contains(warnings[0].Reason, "10.0.0.9") {
+		t.Errorf("ValidateServers() warning = %+v, want web1 redefined with 10.0.0.9", warnings[0])
+	}
+	services := servicesByName(t, "conflicting_servers.conf")
+	if got := services["svc1"].Server.IPAddress; got != "10.0.0.1" {
+		t.Errorf("service svc1 server IP = %q, want the first definition 10.0.0.1", got)
+	}
+	if warnings, err := ValidateServers(fixture("servers_services.conf")); err != nil || len(warnings) != 0 {
+		t.Errorf("ValidateServers(servers_services.conf) = %+v, %v, want no warnings", warnings, err)
+	}
+}
+
+func TestGetServicesLimits(t *testing.T) {
+	services := servicesByName(t, "limits.conf")
+	tests := []struct {
+		name      string
+		maxClient int
+		maxReq    int
+		warnings  int
+	}{
+		{name: "both", maxClient: 100, maxReq: 10},
+		{name: "client", maxClient: 250},
+		{name: "neither"},
+		{name: "trailing", warnings: 1},
+		{name: "invalid", warnings: 1},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.MaxClient != tt.maxClient || service.MaxReq != tt.maxReq {
+			t.Errorf("service %q MaxClient, MaxReq = %d, %d, want %d, %d", tt.name, service.MaxClient, service.MaxReq,
+				tt.maxClient, tt.maxReq)
+		}
+		if len(service.Warnings) != tt.warnings {
+			t.Errorf("service %q warnings = %+v, want %d", tt.name, service.Warnings, tt.warnings)
+		}
+	}
+}
+
+func TestGetServicesEmpty(t *testing.T) {
+	for _, name := range []string{"empty.conf", "comments_only.conf"} {
+		services, err := GetServices(fixture(name))
+		if err != nil || len(services) != 0 {
+			t.Errorf("GetServices(%s) = %+v, %v, want no services and no error", name, services, err)
+		}
+	}
+}
+
+func TestGetServicesQDelimited(t *testing.T) {
+	services := servicesByName(t, "q_delimited.conf")
+	tests := []struct {
+		name   string
+		server string
+		ip     string
+		usip   string
+	}{
+		{name: "classic quotes", server: "web 1", ip: "10.0.0.1", usip: "YES"},
+		{name: `svc "two"`, server: `web "2"`, ip: "10.0.0.2", usip: "YES"},
+		{name: "svc three", server: "web 3", ip: "10.0.0.3", usip: "NO"},
+		{name: "seq/1", server: "web 1", ip: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		service, ok := services[tt.name]
+		if !ok {
+			t.Errorf("GetServices() has no service %q, got %v", tt.name, services)
+			continue
+		}
+		if service.Server.Name != tt.server || service.Server.IPAddress != tt.ip || service.USIP != tt.usip {
+			t.Errorf("service %q = server %q %s, usip %q, want server %q %s, usip %q", tt.name, service.Server.Name,
+				service.Server.IPAddress, service.USIP, tt.server, tt.ip, tt.usip)
+		}
+	}
+	if got := services["svc three"].Server.Comment; got != `moved "here"` {
+		t.Errorf("server web 3 Comment = %q, want %q", got, `moved "here"`)
+	}
+}
+
+func TestRemoveQuoteQDelimited(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: `q/web "1"/`, want: `web "1"`},
+		{line: "q{web 1}", want: "web 1"},
+		{line: "q|a|", want: "a"},
+		{line: `"web 1"`, want: "web 1"},
+		{line: "q{web 1)", want: "q{web 1)"},
+	}
+	for _, tt := range tests {
+		if got := RemoveQuote(tt.line); got != tt.want {
+			t.Errorf("RemoveQuote(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseServerLine(t *testing.T) {
+	tests := []struct {
+		branch  string
+		line    string
+		want    Server
+		wantErr string
+	}{
+		{
+			branch: "quoted name",
+			line:   `"web 1" 10.0.0.1`,
+			want:   Server{Name: "web 1", IPAddress: "10.0.0.1"},
+		},
+		{
+			branch: "quoted name",
+			line:   `"web 1" 10.0.0.1 -comment "first rack"`,
+			want:   Server{Name: "web 1", IPAddress: "10.0.0.1", Comment: "first rack"},
+		},
+		{branch: "quoted name", line: `"web 1"`, wantErr: "missing address"},
+		{branch: "quoted name", line: `"" 10.0.0.1`, wantErr: "missing server name"},
+		{
+			branch: "unquoted name with a quoted comment",
+			line:   `web2 10.0.0.2 -comment "moved from rack 4"`,
+			want:   Server{Name: "web2", IPAddress: "10.0.0.2", Comment: "moved from rack 4"},
+		},
+		{
+			branch: "unquoted name with a quoted comment",
+			line:   `web2 -comment "10.0.0.2" 10.0.0.3`,
+			want:   Server{Name: "web2", IPAddress: "10.0.0.3", Comment: "10.0.0.2"},
+		},
+		{branch: "unquoted name with a quoted comment", line: `web2 -comment "no address"`, wantErr: "missing address"},
+		{
+			branch: "unquoted",
+			line:   "web3 2001:db8::3",
+			want:   Server{Name: "web3", IPAddress: "2001:db8::3"},
+		},
+		{branch: "unquoted", line: "web3", wantErr: "missing address"},
+		{branch: "unquoted", line: "  ", wantErr: "missing server name"},
+	}
+	for _, tt := range tests {
+		server, err := ParseServerLine(tt.line)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: ParseServerLine(%q) = %+v, %v, want error %q", tt.branch, tt.line, server, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseServerLine(%q) error = %v", tt.branch, tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(server, tt.want) {
+			t.Errorf("%s: ParseServerLine(%q) = %+v, want %+v", tt.branch, tt.line, server, tt.want)
+		}
+	}
+}
+
+func TestGetServicesSetService(t *testing.T) {
+	services := servicesByName(t, "usip_set.conf")
+	tests := []struct {
+		name      string
+		usip      string
+		maxClient int
+	}{
+		{name: "svc1", usip: "YES"},
+		{name: "svc2", usip: "NO"},
+		{name: "svc 3", usip: ""},
+		{name: "svc4", usip: "", maxClient: 50},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.USIP != tt.usip || service.MaxClient != tt.maxClient {
+			t.Errorf("service %q USIP, MaxClient = %q, %d, want %q, %d", tt.name, service.USIP, service.MaxClient, tt.usip,
+				tt.maxClient)
+		}
+	}
+	if len(services) != 4 {
+		t.Errorf("GetServices() returned %d services, want 4", len(services))
+	}
+}
+
+func TestGetServicesLineNumbers(t *testing.T) {
+	services := servicesByName(t, "usip_set.conf")
+	lines := strings.Split(readFixture(t, "usip_set.conf"), "\n")
+	want := map[string]int{"svc1": 3, "svc2": 4, "svc 3": 5, "svc4": 6}
+	for name, lineNumber := range want {
+		service := services[name]
+		if service.LineNumber != lineNumber {
+			t.Errorf("service %q LineNumber = %d, want %d", name, service.LineNumber, lineNumber)
+		}
+		if service.RawLine != lines[lineNumber-1] {
+			t.Errorf("service %q RawLine = %q, want %q", name, service.RawLine, lines[lineNumber-1])
+		}
+	}
+	var w strings.Builder
+	if err := WriteJSON(&w, []Service{services["svc2"]}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), `"lineNumber": 4`) || !strings.Contains(w.String(), `"rawLine": "add service svc2`) {
+		t.Errorf("WriteJSON() wrote %s, want the line number and raw line", w.String())
+	}
+}
+
+func TestGetServicesTruncatedLine(t *testing.T) {
+	_, err := GetServices(fixture("truncated_service.conf"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 || !strings.Contains(err.Error(), "missing server") {
+		t.Errorf("GetServices() error = %v, want a ParseError on line 3 for the missing server", err)
+	}
+	const servers = "add server web1 10.0.0.1\nadd server \"web 1\" 10.0.0.1\n"
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: `add service "svc 1"`, want: "missing server"},
+		{line: `add service "svc 1" web1`, want: "missing protocol"},
+		{line: `add service "svc 1" web1 HTTP`, want: "missing port"},
+		{line: `add service svc1 "web 1"`, want: "missing protocol"},
+		{line: "add service svc1", want: "missing server"},
+		{line: "add service svc1 web1 HTTP", want: "missing port"},
+	}
+	for _, tt := range tests {
+		_, err := ParseServices(servers + tt.line + "\n")
+		if err == nil || !strings.Contains(err.Error(), "truncated line: "+tt.want) {
+			t.Errorf("ParseServices(%q) error = %v, want truncated line: %s", tt.line, err, tt.want)
+		}
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	config, err := ParseAll(fixture("full.conf"))
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	counts := []struct {
+		category string
+		got      int
+		want     int
+	}{
+		{category: "servers", got: len(config.Servers), want: 3},
+		{category: "services", got: len(config.Services), want: 4},
+		{category: "service groups", got: len(config.ServiceGroups), want: 2},
+		{category: "vservers", got: len(config.VServers), want: 3},
+	}
+	for _, tt := range counts {
+		if tt.got != tt.want {
+			t.Errorf("ParseAll() returned %d %s, want %d", tt.got, tt.category, tt.want)
+		}
+	}
+	if want := getServices(t, "full.conf"); !reflect.DeepEqual(config.Services, want) {
+		t.Errorf("ParseAll() services = %+v, want those of GetServices %+v", config.Services, want)
+	}
+	if _, err := ParseAll(fixture("nonexistent.conf")); err == nil {
+		t.Error("ParseAll(nonexistent.conf) error = nil, want an error")
+	}
+}
+
+func TestGetServicesTimeouts(t *testing.T) {
+	services := servicesByName(t, "timeouts.conf")
+	tests := []struct {
+		name       string
+		cltTimeout int
+		svrTimeout int
+	}{
+		{name: "both", cltTimeout: 180, svrTimeout: 360},
+		{name: "client", cltTimeout: 120},
+		{name: "neither"},
+		{name: "trailing"},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.CltTimeout != tt.cltTimeout || service.SvrTimeout != tt.svrTimeout {
+			t.Errorf("service %q CltTimeout, SvrTimeout = %d, %d, want %d, %d", tt.name, service.CltTimeout,
+				service.SvrTimeout, tt.cltTimeout, tt.svrTimeout)
+		}
+	}
+}
+
+func TestGetServicesIPv6(t *testing.T) {
+	services := servicesByName(t, "ipv6.conf")
+	tests := []struct {
+		name     string
+		ip       string
+		protocol string
+		port     int
+		usip     string
+	}{
+		{name: "ssl6", ip: "2001:db8::10", protocol: "SSL", port: 443, usip: "YES"},
+		{name: "http 6b", ip: "2001:db8:0:1::20", protocol: "HTTP", port: 8080, usip: "NO"},
+		{name: "any6", ip: "2001:db8::10", protocol: "TCP", port: WildcardPort},
+		{name: "ssl4", ip: "10.0.0.4", protocol: "SSL", port: 443},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.Server.IPAddress != tt.ip || service.Protocol != tt.protocol || service.Port != tt.port ||
+			service.USIP != tt.usip {
+			t.Errorf("service %q = %s %s %d usip %q, want %s %s %d usip %q", tt.name, service.Server.IPAddress,
+				service.Protocol, service.Port, service.USIP, tt.ip, tt.protocol, tt.port, tt.usip)
+		}
+	}
+}
+
+func TestGetServicesWhitespace(t *testing.T) {
+	services := servicesByName(t, "whitespace.conf")
+	tests := []struct {
+		name      string
+		ip        string
+		port      int
+		usip      string
+		inherited bool
+		maxClient int
+		vservers  []string
+	}{
+		{name: "svc1", ip: "10.0.0.1", port: 80, usip: "YES", inherited: true, maxClient: 50},
+		{name: "svc2", ip: "10.0.0.2", port: 443, usip: "NO", vservers: []string{"vs1"}},
+		{name: "svc3", ip: "10.0.0.1", port: 8080, usip: "YES", inherited: true},
+	}
+	for _, tt := range tests {
+		service, ok := services[tt.name]
+		if !ok {
+			t.Errorf("GetServices() has no service %q", tt.name)
+			continue
+		}
+		if service.Server.IPAddress != tt.ip || service.Port != tt.port || service.USIP != tt.usip ||
+			service.USIPInherited != tt.inherited || service.MaxClient != tt.maxClient ||
+			!reflect.DeepEqual(service.VServers, tt.vservers) {
+			t.Errorf("service %q = %+v, want %+v", tt.name, service, tt)
+		}
+	}
+	file, err := os.Open(fixture("whitespace.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanned, err := ScanServices(file)
+	if err != nil {
+		t.Fatalf("ScanServices() error = %v", err)
+	}
+	if want := getServices(t, "whitespace.conf"); !reflect.DeepEqual(scanned, want) {
+		t.Errorf("ScanServices() = %+v, want %+v", scanned, want)
+	}
+	serviceGroups, err := GetServiceGroups(fixture("whitespace.conf"))
+	if err != nil {
+		t.Fatalf("GetServiceGroups() error = %v", err)
+	}
+	if len(serviceGroups) != 1 || !reflect.DeepEqual(memberAddresses(serviceGroups[0]), []string{"10.0.0.2:80"}) {
+		t.Errorf("GetServiceGroups() = %+v, want sg1 with member 10.0.0.2:80", serviceGroups)
+	}
+}
+
+func TestGetServicesHealthMonitor(t *testing.T) {
+	services := servicesByName(t, "health_monitor.conf")
+	want := map[string]bool{"off": false, "on": true, "unspecified": true, "lower": false}
+	for name, healthMonitor := range want {
+		if got := services[name].HealthMonitor; got != healthMonitor {
+			t.Errorf("service %q HealthMonitor = %v, want %v", name, got, healthMonitor)
+		}
+	}
+}
+
+func TestGetServicesTrailingComment(t *testing.T) {
+	services := servicesByName(t, "trailing_comment.conf")
+	want := map[string]string{"svc1": "10.0.0.5", "svc2": "10.0.0.6", "svc4": "2001:db8::4"}
+	for name, ip := range want {
+		if got := services[name].Server.IPAddress; got != ip {
+			t.Errorf("service %q server IP = %q, want %q", name, got, ip)
+		}
+	}
+	issues, err := Validate(fixture("trailing_comment.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Line != 4 || !strings.Contains(issues[0].Message, "missing address") {
+		t.Errorf("Validate() = %v, want server web5 on line 4 missing its address", issues)
+	}
+}
+
+func TestGetServicesInterleavedOptions(t *testing.T) {
+	services := servicesByName(t, "interleaved_options.conf")
+	tests := []struct {
+		name  string
+		usip  string
+		state string
+	}{
+		{name: "svc1", usip: "YES", state: StateEnabled},
+		{name: "svc2", usip: "YES", state: StateEnabled},
+		{name: "svc3", usip: "", state: StateEnabled},
+		{name: "svc 4", usip: "NO", state: StateEnabled},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.USIP != tt.usip || service.State != tt.state {
+			t.Errorf("service %q USIP, State = %q, %q, want %q, %q", tt.name, service.USIP, service.State, tt.usip, tt.state)
+		}
+	}
+}
+
+func TestGetServicesMetacharacters(t *testing.T) {
+	services := servicesByName(t, "metacharacters.conf")
+	tests := []struct {
+		name string
+		ip   string
+		usip string
+	}{
+		{name: "svc.*prod", ip: "10.0.0.1", usip: "YES"},
+		{name: "svcXXprod", ip: "10.0.0.11", usip: "YES"},
+		{name: "svc [a-z]+$", ip: "10.0.0.2", usip: "YES"},
+		{name: "^svc|other", ip: "10.0.0.11", usip: ""},
+	}
+	for _, tt := range tests {
+		service, ok := services[tt.name]
+		if !ok {
+			t.Errorf("GetServices() has no service %q", tt.name)
+			continue
+		}
+		if service.Server.IPAddress != tt.ip || service.USIP != tt.usip {
+			t.Errorf("service %q = %s usip %q, want %s usip %q", tt.name, service.Server.IPAddress, service.USIP, tt.ip,
+				tt.usip)
+		}
+	}
+	if got := services["svc.*prod"].VServers; !reflect.DeepEqual(got, []string{"vs.1"}) {
+		t.Errorf("service %q VServers = %v, want [vs.1]", "svc.*prod", got)
+	}
+	if got := services["svcXXprod"].VServers; len(got) != 0 {
+		t.Errorf("service %q VServers = %v, want none", "svcXXprod", got)
+	}
+	for _, name := range []string{"svc.*prod", "^svc|other"} {
+		service, err := FindService(fixture("metacharacters.conf"), name)
+		if err != nil || service.Name != name {
+			t.Errorf("FindService(%q) = %q, %v, want the service of that exact name", name, service.Name, err)
+		}
+	}
+	if _, err := FindService(fixture("metacharacters.conf"), "svc..prod"); err == nil {
+		t.Error(`FindService("svc..prod") error = nil, want a service not found error`)
+	}
+}
+
+func TestFindServiceEmptyName(t *testing.T) {
+	_, err := FindService(fixture("empty_name.conf"), "svc2")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("FindService() error = %v, want a ParseError on line 3", err)
+	}
+	if _, err := GetServices(fixture("empty_name.conf")); !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("GetServices() error = %v, want a ParseError on line 3", err)
+	}
+}
@@ -0,0 +1,229 @@
+package netscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatPort is a function that returns the config file representation of a service port.
+func FormatPort(port int) string {
+	if port == WildcardPort {
+		return "*"
+	}
+	return strconv.Itoa(port)
+}
+
+// writers holds the function that writes each of the output formats accepted by WriteResults, keyed by format name.
+var writers = map[string]func(io.Writer, []Service) error{
+	"text":  WriteText,
+	"table": WriteTable,
+	"json":  WriteJSON,
+	"jsonl": WriteJSONL,
+	"csv":   WriteCSV,
+	"tsv":   WriteTSV,
+	"html":  WriteHTML,
+}
+
+// KnownFormat is a function that reports whether format is one of the output formats accepted by WriteResults.
+func KnownFormat(format string) bool {
+	_, ok := writers[format]
+	return ok
+}
+
+// WriteResults is a function that writes services to w in the named format: text, table, json, jsonl, csv, tsv or
+// html.  It is the single entry point for the output formats, leaving the caller to choose where the output goes.
+func WriteResults(w io.Writer, services []Service, format string) error {
+	writer, ok := writers[format]
+	if !ok {
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+	return writer(w, services)
+}
+
+// WriteText is a function that writes one line per service to w containing the service name, server name and server
+// address separated by spaces, the address of a server defined by domain name being the domain.  A field that is empty
+// or contains a space or a quote is quoted as it is in a configuration, as in "svc 2" "web 2" 10.0.0.2, so that the
+// fields can always be told apart.  The tsv and jsonl formats are better suited to other programs.
+func WriteText(w io.Writer, services []Service) error {
+	for _, service := range services {
+		_, err := fmt.Fprintln(w, quoteName(service.Name)+" "+quoteName(service.Server.Name)+" "+
+			quoteName(service.Server.Address()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTable is a function that writes services to w as a table of the DefaultColumns with a header row and aligned
+// columns.
+func WriteTable(w io.Writer, services []Service) error {
+	columns, err := SelectColumns(nil)
+	if err != nil {
+		return err
+	}
+	return WriteTableColumns(w, services, columns)
+}
+
+// jsonService is the JSON representation of a single USIP service.
+type jsonService struct {
+	Name       string   `json:"name"`
+	Server     string   `json:"server"`
+	IP         string   `json:"ip"`
+	Domain     string   `json:"domain,omitempty"`
+	Comment    string   `json:"comment,omitempty"`
+	Protocol   string   `json:"protocol"`
+	Port       int      `json:"port"`
+	USIP       string   `json:"usip"`
+	Inherited  bool     `json:"usipInherited,omitempty"`
+	State      string   `json:"state"`
+	CltTimeout int      `json:"cltTimeout,omitempty"`
+	SvrTimeout int      `json:"svrTimeout,omitempty"`
+	VServers   []string `json:"vservers,omitempty"`
+	SourceFile string   `json:"sourceFile,omitempty"`
+	LineNumber int      `json:"lineNumber,omitempty"`
+	RawLine    string   `json:"rawLine,omitempty"`
+}
+
+// newJSONService is a function that returns the JSON record written for service.
+func newJSONService(service Service) jsonService {
+	return jsonService{
+		Name:       service.Name,
+		Server:     service.Server.Name,
+		IP:         service.Server.IPAddress,
+		Domain:     service.Server.Domain,
+		Comment:    service.Server.Comment,
+		Protocol:   service.Protocol,
+		Port:       service.Port,
+		USIP:       service.USIP,
+		Inherited:  service.USIPInherited,
+		State:      service.State,
+		CltTimeout: service.CltTimeout,
+		SvrTimeout: service.SvrTimeout,
+		VServers:   service.VServers,
+		SourceFile: service.SourceFile,
+		LineNumber: service.LineNumber,
+		RawLine:    service.RawLine,
+	}
+}
+
+// WriteJSON is a function that writes services to w as an indented JSON array.  The services are written in the
+// order given and an empty slice is written as [] rather than null.
+func WriteJSON(w io.Writer, services []Service) error {
+	records := make([]jsonService, 0, len(services))
+	for _, service := range services {
+		records = append(records, newJSONService(service))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// WriteJSONL is a function that writes services to w as newline-delimited JSON, one compact object per line, for
+// tools that ingest log lines.  Nothing is written when there are no services.
+func WriteJSONL(w io.Writer, services []Service) error {
+	encoder := json.NewEncoder(w)
+	for _, service := range services {
+		if err := encoder.Encode(newJSONService(service)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvField is a function that quotes a CSV field when it contains a comma, a space, a quote or a line break.  Quotes
+// within the field are doubled.
+func csvField(field string) string {
+	if !strings.ContainsAny(field, ", \"\r\n") {
+		return field
+	}
+	return `"` + strings.Replace(field, `"`, `""`, -1) + `"`
+}
+
+// WriteCSV is a function that writes services to w as CSV of the DefaultColumns with a header row.  The cltTimeout and
+// svrTimeout columns are added when any of the services has a timeout set.  The header is written even when there are
+// no services.
+func WriteCSV(w io.Writer, services []Service) error {
+	names := DefaultColumns
+	if hasTimeouts(services) {
+		names = append(append([]string{}, DefaultColumns...), "cltTimeout", "svrTimeout")
+	}
+	columns, err := SelectColumns(names)
+	if err != nil {
+		return err
+	}
+	return WriteCSVColumns(w, services, columns)
+}
+
+// hasTimeouts is a function that reports whether any of services has a client or server idle timeout set.
+func hasTimeouts(services []Service) bool {
+	for _, service := range services {
+		if service.CltTimeout != 0 || service.SvrTimeout != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTSV is a function that writes services to w as tab-separated values of the DefaultColumns with a header row.
+func WriteTSV(w io.Writer, services []Service) error {
+	columns, err := SelectColumns(nil)
+	if err != nil {
+		return err
+	}
+	return WriteTSVColumns(w, services, columns)
+}
+
+// htmlTemplate is the template for the HTML report written by WriteHTML.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"port": FormatPort}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>USIP services</title>
+</head>
+<body>
+<h1>USIP services</h1>
+{{if .Files}}<p>Source: {{range $ix, $file := .Files}}{{if $ix}}, {{end}}{{$file}}{{end}}</p>
+{{end}}<p>Generated: {{.Generated}}</p>
+{{if .Services}}<table>
+<tr><th>Name</th><th>Server</th><th>IP</th><th>Protocol</th><th>Port</th><th>USIP</th></tr>
+{{range .Services}}<tr><td>{{.Name}}</td><td>{{.Server.Name}}</td><td>{{.Server.Address}}</td><td>{{.Protocol}}</td><td>{{port .Port}}</td><td>{{.USIP}}</td></tr>
+{{end}}</table>
+{{else}}<p>No USIP services found</p>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML is a function that writes services to w as an HTML report containing a table of the services, the files
+// they were read from and the time the report was generated.  All fields are escaped by html/template.
+func WriteHTML(w io.Writer, services []Service) error {
+	var files []string
+	seen := make(map[string]bool)
+	for _, service := range services {
+		file := service.SourceFile
+		if file == "" {
+			file = "stdin"
+		}
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return htmlTemplate.Execute(w, struct {
+		Files     []string
+		Generated string
+		Services  []Service
+	}{files, time.Now().Format(time.RFC1123), services})
+}
+
+// WriteConfig is a function that writes the complete parsed configuration to w as a single indented JSON document.
+func WriteConfig(w io.Writer, config Config) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(config)
+}
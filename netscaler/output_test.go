@@ -0,0 +1,181 @@
+package netscaler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// outputServices is a function that returns the services written by the output tests: one with USIP enabled and one
+// with a name containing a space, an IPv6 server, the wildcard port and no usip value.
+func outputServices() []Service {
+	return []Service{
+		NewService("svc1", Server{Name: "web1", IPAddress: "10.0.0.1"}, "HTTP", 80, "YES"),
+		NewService("long service", Server{Name: "web2", IPAddress: "2001:db8::1"}, "SSL", WildcardPort, ""),
+	}
+}
+
+// writeString is a function that returns what write writes for services, failing the test on an error.
+func writeString(t *testing.T, write func(io.Writer, []Service) error, services []Service) string {
+	t.Helper()
+	var w bytes.Buffer
+	if err := write(&w, services); err != nil {
+		t.Fatalf("write error = %v", err)
+	}
+	return w.String()
+}
+
+func TestWriteTable(t *testing.T) {
+	got := writeString(t, WriteTable, outputServices())
+	want := "NAME          SERVER  IP           PROTOCOL  PORT  USIP\n" +
+		"svc1          web1    10.0.0.1     HTTP      80    YES\n" +
+		"long service  web2    2001:db8::1  SSL       *     \n"
+	if got != want {
+		t.Errorf("WriteTable() wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	services := outputServices()
+	services[0].SourceFile = "ns.conf"
+	got := writeString(t, WriteJSONL, services)
+	scanner := bufio.NewScanner(strings.NewReader(got))
+	var records []jsonService
+	for scanner.Scan() {
+		var record jsonService
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("WriteJSONL() line %q does not parse: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	want := []jsonService{newJSONService(services[0]), newJSONService(services[1])}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("WriteJSONL() lines parse to %+v, want %+v", records, want)
+	}
+	if records[0].SourceFile != "ns.conf" || records[1].Port != WildcardPort {
+		t.Errorf("WriteJSONL() records = %+v, want the source file and the wildcard port", records)
+	}
+	if got := writeString(t, WriteJSONL, nil); got != "" {
+		t.Errorf("WriteJSONL(nil) wrote %q, want nothing", got)
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	services := outputServices()
+	services = append(services, NewService("tab\tbed", Server{Name: "web3", IPAddress: "10.0.0.3"}, "TCP", 8080, "NO"))
+	got := writeString(t, WriteTSV, services)
+	want := "name\tserver\tip\tprotocol\tport\tusip\n" +
+		"svc1\tweb1\t10.0.0.1\tHTTP\t80\tYES\n" +
+		"long service\tweb2\t2001:db8::1\tSSL\t*\t\n" +
+		"tabbed\tweb3\t10.0.0.3\tTCP\t8080\tNO\n"
+	if got != want {
+		t.Errorf("WriteTSV() wrote %q, want %q", got, want)
+	}
+}
+
+func TestWriteTextRoundTrip(t *testing.T) {
+	services := []Service{
+		NewService("svc 2", Server{Name: "web 2", IPAddress: "10.0.0.2"}, "HTTP", 80, "YES"),
+		NewService(`say "hi"`, Server{Name: "web1", IPAddress: "10.0.0.1"}, "HTTP", 80, "YES"),
+		NewService("svc1", Server{Name: "web3", Domain: "web.example.com"}, "HTTP", 80, "YES"),
+	}
+	got := writeString(t, WriteText, services)
+	want := "\"svc 2\" \"web 2\" 10.0.0.2\n\"say \\\"hi\\\"\" web1 10.0.0.1\nsvc1 web3 web.example.com\n"
+	if got != want {
+		t.Errorf("WriteText() wrote %q, want %q", got, want)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	for ix, line := range lines {
+		fields := splitFields(line)
+		if len(fields) != 3 {
+			t.Errorf("WriteText() line %q has %d fields, want 3", line, len(fields))
+			continue
+		}
+		service := services[ix]
+		if RemoveQuote(fields[0]) != service.Name || RemoveQuote(fields[1]) != service.Server.Name ||
+			RemoveQuote(fields[2]) != service.Server.Address() {
+			t.Errorf("WriteText() line %q reads back as %q, want %q %q %q", line, fields, service.Name,
+				service.Server.Name, service.Server.Address())
+		}
+	}
+}
+
+func TestWriteDomainServer(t *testing.T) {
+	services := []Service{NewService("svc1", Server{Name: "webdns", Domain: "web.example.com"}, "HTTP", 80, "YES")}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"text", "svc1 webdns web.example.com\n"},
+		{"csv", "name,server,ip,protocol,port,usip\nsvc1,webdns,web.example.com,HTTP,80,YES\n"},
+		{"tsv", "name\tserver\tip\tprotocol\tport\tusip\nsvc1\twebdns\tweb.example.com\tHTTP\t80\tYES\n"},
+		{"jsonl", `{"name":"svc1","server":"webdns","ip":"","domain":"web.example.com","protocol":"HTTP","port":80,` +
+			`"usip":"YES","state":"ENABLED"}` + "\n"},
+	}
+	for _, tt := range tests {
+		var w bytes.Buffer
+		if err := WriteResults(&w, services, tt.format); err != nil {
+			t.Fatalf("WriteResults(%q) error = %v", tt.format, err)
+		}
+		if got := w.String(); got != tt.want {
+			t.Errorf("WriteResults(%q) wrote %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSVTimeouts(t *testing.T) {
+	services := outputServices()
+	if got := writeString(t, WriteCSV, services); strings.Contains(got, "Timeout") {
+		t.Errorf("WriteCSV() without timeouts wrote %q, want no timeout columns", got)
+	}
+	services[0].CltTimeout = 180
+	got := writeString(t, WriteCSV, services)
+	want := "name,server,ip,protocol,port,usip,cltTimeout,svrTimeout\n" +
+		"svc1,web1,10.0.0.1,HTTP,80,YES,180,\n" +
+		"\"long service\",web2,2001:db8::1,SSL,*,,,\n"
+	if got != want {
+		t.Errorf("WriteCSV() wrote %q, want %q", got, want)
+	}
+	if got := writeString(t, WriteJSONL, services[:1]); !strings.Contains(got, `"cltTimeout":180`) ||
+		strings.Contains(got, "svrTimeout") {
+		t.Errorf("WriteJSONL() wrote %q, want cltTimeout only", got)
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: "text", want: []string{"svc1 web1 10.0.0.1\n\"long service\" web2 2001:db8::1\n"}},
+		{format: "table", want: []string{"NAME          SERVER", "long service  web2    2001:db8::1  SSL       *"}},
+		{format: "csv", want: []string{"name,server,ip,protocol,port,usip\nsvc1,web1,10.0.0.1,HTTP,80,YES\n"}},
+		{format: "tsv", want: []string{"name\tserver\tip\tprotocol\tport\tusip\nsvc1\tweb1\t10.0.0.1\tHTTP\t80\tYES\n"}},
+		{format: "json", want: []string{"[\n  {\n    \"name\": \"svc1\",", "\"ip\": \"2001:db8::1\""}},
+		{format: "jsonl", want: []string{`{"name":"svc1","server":"web1","ip":"10.0.0.1",`, "\n{\"name\":\"long service\""}},
+		{format: "html", want: []string{"<title>USIP services</title>", "long service", "2001:db8::1"}},
+	}
+	for _, tt := range tests {
+		var w bytes.Buffer
+		if err := WriteResults(&w, outputServices(), tt.format); err != nil {
+			t.Errorf("WriteResults(%s) error = %v", tt.format, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(w.String(), want) {
+				t.Errorf("WriteResults(%s) wrote %q, want it to contain %q", tt.format, w.String(), want)
+			}
+		}
+	}
+	if len(tests) != len(writers) {
+		t.Errorf("TestWriteResults covers %d formats, want all %d", len(tests), len(writers))
+	}
+	var w bytes.Buffer
+	if err := WriteResults(&w, outputServices(), "yaml"); err == nil || w.Len() != 0 {
+		t.Errorf("WriteResults(yaml) = %q, %v, want an unknown format error and no output", w.String(), err)
+	}
+}
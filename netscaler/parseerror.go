@@ -0,0 +1,87 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseError is a data structure for a malformed line found while parsing a configuration, returned so that callers
+// can read its details with errors.As.  Line is the 1-based line number and Column the 1-based column of the offending
+// value, counted from the start of the command, or 0 when no single value is to blame.  Err is the underlying error,
+// so that errors.Is still finds errors such as ErrServerNotFound.
+type ParseError struct {
+	Line   int
+	Column int
+	Reason string
+	Err    error
+}
+
+// Error is a method that returns the error as a single line, prefixed with its line number as earlier errors were.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Unwrap is a method that returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// tokenError is a data structure for an error caused by a single value of a line, which newParseError uses to find
+// the column of the error.  end marks an error caused by the line ending too soon.
+type tokenError struct {
+	token string
+	end   bool
+	err   error
+}
+
+// Error is a method that returns the message of the underlying error.
+func (e *tokenError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap is a method that returns the underlying error.
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+// newParseError is a function that returns the ParseError for err found on line, whose Reason is what was being
+// parsed followed by the message of err.
+func newParseError(line ConfigLine, what string, err error) *ParseError {
+	parseErr := &ParseError{Line: line.Number, Reason: what + ": " + err.Error(), Err: err}
+	var tokenErr *tokenError
+	if !errors.As(err, &tokenErr) {
+		return parseErr
+	}
+	text := strings.TrimRightFunc(line.Text, unicode.IsSpace)
+	if tokenErr.end {
+		parseErr.Column = len(text) + 1
+		return parseErr
+	}
+	parseErr.Column = fieldColumn(text, tokenErr.token)
+	if parseErr.Column == 0 {
+		parseErr.Column = fieldColumn(text, quoteName(tokenErr.token))
+	}
+	return parseErr
+}
+
+// fieldColumn is a function that returns the 1-based column of the first whitespace-separated field of text that is
+// token, or 0 if there is none.
+func fieldColumn(text, token string) int {
+	if token == "" {
+		return 0
+	}
+	for offset := 0; offset < len(text); {
+		ix := strings.Index(text[offset:], token)
+		if ix < 0 {
+			return 0
+		}
+		start, end := offset+ix, offset+ix+len(token)
+		if (start == 0 || unicode.IsSpace(rune(text[start-1]))) && (end == len(text) || unicode.IsSpace(rune(text[end]))) {
+			return start + 1
+		}
+		offset = start + 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package netscaler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorFields(t *testing.T) {
+	_, err := GetServices(fixture("malformed.conf"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("GetServices() error = %v, want a *ParseError", err)
+	}
+	if parseErr.Line != 4 || parseErr.Column != 28 {
+		t.Errorf("ParseError Line, Column = %d, %d, want 4, 28", parseErr.Line, parseErr.Column)
+	}
+	const reason = `malformed add service: service svc2: invalid port "70000": must be a number between 1 and 65535 or *`
+	if parseErr.Reason != reason {
+		t.Errorf("ParseError Reason = %q, want %q", parseErr.Reason, reason)
+	}
+	if want := "line 4: " + reason; err.Error() != want {
+		t.Errorf("ParseError.Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestParseErrorColumn(t *testing.T) {
+	const servers = "add server web1 10.0.0.1\n"
+	tests := []struct {
+		line   string
+		column int
+		target error
+	}{
+		{line: "add service svc2 gone HTTP 80", column: 18, target: ErrServerNotFound},
+		{line: "add service svc2 web1 HTTP abc", column: 28},
+		{line: `add service "svc 2" web1 HTTP`, column: 30},
+	}
+	for _, tt := range tests {
+		_, err := ParseServices(servers + tt.line + "\n")
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseServices(%q) error = %v, want a *ParseError", tt.line, err)
+			continue
+		}
+		if parseErr.Line != 2 || parseErr.Column != tt.column {
+			t.Errorf("ParseServices(%q) Line, Column = %d, %d, want 2, %d", tt.line, parseErr.Line, parseErr.Column,
+				tt.column)
+		}
+		if tt.target != nil && !errors.Is(err, tt.target) {
+			t.Errorf("ParseServices(%q) error = %v, want errors.Is %v", tt.line, err, tt.target)
+		}
+	}
+}
+
+func TestParseErrorFixtures(t *testing.T) {
+	getServiceGroups := func(name string) error {
+		_, err := GetServiceGroups(name)
+		return err
+	}
+	getVServers := func(name string) error {
+		_, err := GetVServers(name)
+		return err
+	}
+	tests := []struct {
+		name   string
+		parse  func(string) error
+		line   int
+		column int
+		reason string
+	}{
+		{"malformed_servicegroup.conf", getServiceGroups, 4, 28,
+			`malformed bind serviceGroup: invalid port "abc": must be a number between 1 and 65535 or *`},
+		{"malformed_unbind.conf", getServiceGroups, 4, 30,
+			`malformed bind serviceGroup: invalid port "70000": must be a number between 1 and 65535 or *`},
+		{"malformed_vserver.conf", getVServers, 4, 35,
+			`malformed add lb vserver: invalid port "http": must be a number between 1 and 65535 or *`},
+	}
+	for _, tt := range tests {
+		err := tt.parse(fixture(tt.name))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: error = %v, want a *ParseError", tt.name, err)
+			continue
+		}
+		if parseErr.Line != tt.line || parseErr.Column != tt.column || parseErr.Reason != tt.reason {
+			t.Errorf("%s: ParseError = %d:%d %q, want %d:%d %q", tt.name, parseErr.Line, parseErr.Column,
+				parseErr.Reason, tt.line, tt.column, tt.reason)
+		}
+	}
+}
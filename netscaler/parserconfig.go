@@ -0,0 +1,75 @@
+package netscaler
+
+// The patterns used to find "add server" and "add service" lines when a ParserConfig does not set its own.  The
+// keywords may be separated by any run of spaces and tabs.
+const (
+	DefaultServerPattern  = `(?m)^[ \t]*add[ \t]+server[ \t].*`
+	DefaultServicePattern = `(?m)^[ \t]*add[ \t]+service[ \t].*`
+)
+
+// ParserConfig is a data structure for the options that change how a NetScaler configuration is parsed.  The zero
+// value parses the configuration in the default way.
+type ParserConfig struct {
+	// ServerPattern is the regular expression that matches the "add server" lines.  It defaults to
+	// DefaultServerPattern.
+	ServerPattern string
+	// ServicePattern is the regular expression that matches the "add service" lines.  It defaults to
+	// DefaultServicePattern.  Overriding it can, for example, restrict parsing to services with a given name prefix.
+	ServicePattern string
+	// SkipMissingServers makes a service or service group member whose server is not defined be logged and skipped
+	// instead of failing the whole parse.
+	SkipMissingServers bool
+	// CaseInsensitiveNames makes a service find its server when the two spell the server name in different cases, as
+	// in WebServer1 and webserver1.  By default names must match exactly.
+	CaseInsensitiveNames bool
+	// Hook, when set, is called with each parsed service, once the lines that change or bind to it have been applied,
+	// and the service it returns is used in its place.  It can rename, tag or otherwise enrich services as they are
+	// parsed.
+	Hook func(Service) Service
+	// Limit, when greater than zero, stops parsing once that many services have been collected, so that the start of
+	// a large configuration can be inspected without parsing all of it.
+	Limit int
+	// Encoding is the encoding of the configuration files read by GetServices, GetAllServices and ScanServices, one of
+	// EncodingUTF8 and EncodingLatin1, which are decoded to UTF-8 before parsing.  It defaults to EncodingUTF8.
+	Encoding string
+}
+
+// getFile is a method that returns the contents of the named file, as GetFile does, decoded from the Encoding.
+func (c ParserConfig) getFile(fileName string) (string, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	return Decode(file, c.Encoding)
+}
+
+// serverPattern is a method that returns the pattern for "add server" lines.
+func (c ParserConfig) serverPattern() string {
+	if c.ServerPattern == "" {
+		return DefaultServerPattern
+	}
+	return c.ServerPattern
+}
+
+// servicePattern is a method that returns the pattern for "add service" lines.
+func (c ParserConfig) servicePattern() string {
+	if c.ServicePattern == "" {
+		return DefaultServicePattern
+	}
+	return c.ServicePattern
+}
+
+// limitReached is a method that reports whether count services are as many as the Limit allows.
+func (c ParserConfig) limitReached(count int) bool {
+	return c.Limit > 0 && count >= c.Limit
+}
+
+// applyHook is a method that replaces each of services with the result of calling the Hook on it, if there is one.
+func (c ParserConfig) applyHook(services []Service) {
+	if c.Hook == nil {
+		return
+	}
+	for ix := range services {
+		services[ix] = c.Hook(services[ix])
+	}
+}
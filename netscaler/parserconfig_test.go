@@ -0,0 +1,115 @@
+package netscaler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParserConfigServicePattern(t *testing.T) {
+	config := ParserConfig{ServicePattern: `(?m)^add service prod-.*`}
+	file := "add server web1 10.0.0.1\nadd service prod-web web1 HTTP 80\nadd service test-web web1 HTTP 80\n" +
+		"add service prod-api web1 HTTP 8080\n"
+	services, err := config.ParseServices(context.Background(), file)
+	if err != nil {
+		t.Fatalf("ParseServices() error = %v", err)
+	}
+	if got, want := serviceNames(services), []string{"prod-web", "prod-api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseServices() = %v, want %v", got, want)
+	}
+	if _, err := (ParserConfig{ServicePattern: "("}).ParseServices(context.Background(), file); err == nil {
+		t.Error("ParseServices() with an invalid pattern: error = nil, want an error")
+	}
+}
+
+func TestParserConfigSkipMissingServers(t *testing.T) {
+	file := readFixture(t, "missing_server.conf")
+	services, err := ParserConfig{SkipMissingServers: true}.ParseAllServices(context.Background(), file)
+	if err != nil {
+		t.Fatalf("ParseAllServices() error = %v", err)
+	}
+	want := []string{"svc1", "svc3", "sg1"}
+	if got := serviceNames(services); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAllServices() = %v, want %v", got, want)
+	}
+	if _, err := (ParserConfig{}).ParseAllServices(context.Background(), file); !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("ParseAllServices() without SkipMissingServers: error = %v, want %v", err, ErrServerNotFound)
+	}
+}
+
+func TestGetServicesWithHook(t *testing.T) {
+	var seen []string
+	hook := func(service Service) Service {
+		seen = append(seen, service.Name+":"+service.USIP)
+		service.Name = strings.ToUpper(service.Name)
+		return service
+	}
+	services, err := GetServicesWithHook(fixture("servers_services.conf"), hook)
+	if err != nil {
+		t.Fatalf("GetServicesWithHook() error = %v", err)
+	}
+	if got, want := serviceNames(services), []string{"SVC1", "SVC2", "SVC3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetServicesWithHook() = %v, want %v", got, want)
+	}
+	if want := []string{"svc1:YES", "svc2:NO", "svc3:"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("hook called with %v, want %v", seen, want)
+	}
+}
+
+func TestParserConfigCaseInsensitiveNames(t *testing.T) {
+	if _, err := GetServices(fixture("mixed_case_names.conf")); !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("GetServices() error = %v, want %v", err, ErrServerNotFound)
+	}
+	config := ParserConfig{CaseInsensitiveNames: true}
+	services, err := config.GetAllServices(context.Background(), fixture("mixed_case_names.conf"))
+	if err != nil {
+		t.Fatalf("GetAllServices() error = %v", err)
+	}
+	got := make(map[string]string)
+	var members []string
+	for _, service := range services {
+		if service.Name == "sg1" {
+			members = append(members, service.Server.IPAddress+":"+FormatPort(service.Port))
+			continue
+		}
+		got[service.Name] = service.Server.IPAddress
+	}
+	if want := map[string]string{"svc1": "10.0.0.1", "svc2": "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllServices() servers = %v, want %v", got, want)
+	}
+	if wantMembers := []string{"10.0.0.1:80", "10.0.0.2:8080"}; !reflect.DeepEqual(members, wantMembers) {
+		t.Errorf("GetAllServices() service group members = %v, want %v", members, wantMembers)
+	}
+}
+
+func TestParserConfigLimit(t *testing.T) {
+	// The malformed line follows the first 20 services, so only a parse that stops at the limit gets by without an error.
+	config := strings.Replace(generateConfig(10, 50), "add service svc20 ",
+		"add service bad web0 HTTP abc\nadd service svc20 ", 1)
+	tests := []struct {
+		limit   int
+		want    int
+		wantErr bool
+	}{
+		{limit: 0, wantErr: true},
+		{limit: 1, want: 1},
+		{limit: 20, want: 20},
+		{limit: 21, wantErr: true},
+		{limit: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		parserConfig := ParserConfig{Limit: tt.limit}
+		services, err := parserConfig.ParseServices(context.Background(), config)
+		if (err != nil) != tt.wantErr || len(services) != tt.want {
+			t.Errorf("ParseServices(Limit %d) = %d services, %v, want %d services, error %t", tt.limit, len(services),
+				err, tt.want, tt.wantErr)
+		}
+		scanned, err := parserConfig.ScanServices(context.Background(), strings.NewReader(config))
+		if (err != nil) != tt.wantErr || len(scanned) != tt.want {
+			t.Errorf("ScanServices(Limit %d) = %d services, %v, want %d services, error %t", tt.limit, len(scanned),
+				err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package netscaler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// knownProtocols is the set of NetScaler service types, in upper case.
+var knownProtocols = map[string]bool{
+	"ADNS": true, "ADNS_TCP": true, "ANY": true, "DHCPRA": true, "DIAMETER": true, "DNS": true, "DNS_TCP": true,
+	"DTLS": true, "FIX": true, "FTP": true, "GRE": true, "HTTP": true, "IPFIX": true, "LDNS": true, "LOGSTREAM": true,
+	"MONGO": true, "MONGO_TLS": true, "MQTT": true, "MQTT_TLS": true, "MSSQL": true, "MYSQL": true, "NNTP": true,
+	"ORACLE": true, "PPTP": true, "QUIC": true, "QUIC_BRIDGE": true, "RADIUS": true, "RADIUSLISTENER": true,
+	"RDP": true, "RPCSVR": true, "RTSP": true, "SIP_SSL": true, "SIP_TCP": true, "SIP_UDP": true, "SMPP": true,
+	"SNMP": true, "SSL": true, "SSL_BRIDGE": true, "SSL_DIAMETER": true, "SSL_FIX": true, "SSL_TCP": true,
+	"SYSLOGTCP": true, "SYSLOGUDP": true, "TCP": true, "TFTP": true, "UDP": true, "USER_SSL_TCP": true,
+	"USER_TCP": true,
+}
+
+// normalizeProtocol is a function that returns protocol in the upper case that NetScaler writes service types in, so
+// that ssl, Ssl and SSL are all SSL.
+func normalizeProtocol(protocol string) string {
+	return strings.ToUpper(strings.TrimSpace(protocol))
+}
+
+// KnownProtocol is a function that reports whether protocol is a NetScaler service type, ignoring case.
+func KnownProtocol(protocol string) bool {
+	return knownProtocols[normalizeProtocol(protocol)]
+}
+
+// IsSSLProtocol is a function that reports whether protocol is one of the SSL service types, SSL and SSL_TCP, that
+// are expected to have a certificate bound.
+func IsSSLProtocol(protocol string) bool {
+	switch normalizeProtocol(protocol) {
+	case "SSL", "SSL_TCP":
+		return true
+	}
+	return false
+}
+
+// ParseWarning is a data structure for a suspect value found while parsing.  Unlike an error, a warning does not stop
+// the parse; it is attached to the parsed value so that misaligned fields can be spotted.
+type ParseWarning struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// String is a method that returns the warning as a single line.
+func (w ParseWarning) String() string {
+	return fmt.Sprintf("%s %q: %s", w.Field, w.Value, w.Reason)
+}
+
+// checkProtocol is a function that adds a ParseWarning to service when its protocol is not a known NetScaler service
+// type, which usually means the fields of the line were misaligned.
+func checkProtocol(service *Service) {
+	if KnownProtocol(service.Protocol) {
+		return
+	}
+	warning := ParseWarning{Field: "protocol", Value: service.Protocol, Reason: "unknown service type"}
+	service.Warnings = append(service.Warnings, warning)
+}
@@ -0,0 +1,75 @@
+package netscaler
+
+import (
+	"testing"
+)
+
+func TestKnownProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     bool
+	}{
+		{protocol: "HTTP", want: true},
+		{protocol: "ssl_tcp", want: true},
+		{protocol: "10.0.0.1", want: false},
+		{protocol: "HTTPS", want: false},
+	}
+	for _, tt := range tests {
+		if got := KnownProtocol(tt.protocol); got != tt.want {
+			t.Errorf("KnownProtocol(%q) = %v, want %v", tt.protocol, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesProtocolWarnings(t *testing.T) {
+	services := servicesByName(t, "protocols.conf")
+	if warnings := services["svc1"].Warnings; len(warnings) != 0 {
+		t.Errorf("service svc1: Warnings = %v, want none", warnings)
+	}
+	warnings := services["svc2"].Warnings
+	want := ParseWarning{Field: "protocol", Value: "10.0.0.1", Reason: "unknown service type"}
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("service svc2: Warnings = %v, want [%v]", warnings, want)
+	}
+}
+
+func TestNormalizeProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{protocol: "SSL", want: "SSL"},
+		{protocol: "ssl", want: "SSL"},
+		{protocol: "Ssl_Tcp", want: "SSL_TCP"},
+		{protocol: " http ", want: "HTTP"},
+	}
+	for _, tt := range tests {
+		if got := normalizeProtocol(tt.protocol); got != tt.want {
+			t.Errorf("normalizeProtocol(%q) = %q, want %q", tt.protocol, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesProtocolCase(t *testing.T) {
+	services := servicesByName(t, "protocols.conf")
+	tests := []struct {
+		name        string
+		protocol    string
+		rawProtocol string
+	}{
+		{name: "svc1", protocol: "HTTP", rawProtocol: "HTTP"},
+		{name: "svc3", protocol: "SSL", rawProtocol: "ssl"},
+		{name: "svc4", protocol: "SSL_TCP", rawProtocol: "Ssl_Tcp"},
+	}
+	for _, tt := range tests {
+		service := services[tt.name]
+		if service.Protocol != tt.protocol || service.RawProtocol != tt.rawProtocol {
+			t.Errorf("service %q Protocol, RawProtocol = %q, %q, want %q, %q", tt.name, service.Protocol,
+				service.RawProtocol, tt.protocol, tt.rawProtocol)
+		}
+	}
+	filtered := FilterByProtocol(getServices(t, "protocols.conf"), []string{"ssl"})
+	if got := serviceNames(filtered); !equalNames(got, []string{"svc3"}) {
+		t.Errorf("FilterByProtocol(ssl) = %v, want [svc3]", got)
+	}
+}
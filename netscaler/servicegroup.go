@@ -0,0 +1,267 @@
+package netscaler
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ServiceGroup is a data structure for NetScaler Load Balancing service group data.
+type ServiceGroup struct {
+	Name          string               `json:"name"`
+	Protocol      string               `json:"protocol"`
+	RawProtocol   string               `json:"rawProtocol,omitempty"`
+	USIP          string               `json:"usip"`
+	USIPInherited bool                 `json:"usipInherited,omitempty"`
+	State         string               `json:"state,omitempty"`
+	Members       []ServiceGroupMember `json:"members"`
+}
+
+// ServiceGroupMember is a data structure for a server bound to a service group along with the port it is bound on.
+type ServiceGroupMember struct {
+	Server
+	Port int `json:"port"`
+}
+
+// Services is a method that returns one Service for each member of the service group, so that members can be
+// filtered and written in the same way as individual services.  The members take the state of the service group.
+func (g ServiceGroup) Services() []Service {
+	var services []Service
+	for _, member := range g.Members {
+		service := NewService(g.Name, member.Server, g.Protocol, member.Port, g.USIP)
+		service.RawProtocol = g.RawProtocol
+		service.USIPInherited = g.USIPInherited
+		if g.State != "" {
+			service.State = g.State
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// GetServiceGroups is a function that returns an array of Load Balancing service groups along with their members.
+// It accepts a filename as a parameter.
+func GetServiceGroups(fileName string) ([]ServiceGroup, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParserConfig{}.parseServiceGroups(file)
+}
+
+// The "add serviceGroup" and "bind serviceGroup" lines are matched both when parsing and when validating, so their
+// regular expressions are compiled once.
+var (
+	addServiceGroupRegexp  = regexp.MustCompile(`(?m)^[ \t]*add[ \t]+serviceGroup[ \t].*`)
+	bindServiceGroupRegexp = regexp.MustCompile(`(?m)^[ \t]*(?:un)?bind[ \t]+serviceGroup[ \t].*`)
+)
+
+// parseServiceGroups is a method that returns the service groups defined within the contents of a NetScaler
+// configuration, looking up the servers of their members with the options of the ParserConfig.  Members are read from
+// the "bind serviceGroup" lines and options changed by "set serviceGroup" lines are applied after the group is added.
+// "unset serviceGroup" and "unbind serviceGroup" lines are applied in the same passes, so that the last line wins.
+// A group without a usip value of its own takes the global one, as services do.  As for services, a member whose server
+// is missing is skipped when SkipMissingServers is set.
+func (c ParserConfig) parseServiceGroups(file string) ([]ServiceGroup, error) {
+	var serviceGroups []ServiceGroup
+	for _, addServiceGroupLine := range findConfigLines(file, addServiceGroupRegexp) {
+		serviceGroup, err := parseAddServiceGroupLine(addServiceGroupLine)
+		if err != nil {
+			return nil, err
+		}
+		serviceGroups = append(serviceGroups, serviceGroup)
+	}
+	setServiceGroupLines, err := GetConfigLines(file, `(?m)^[ \t]*(?:un)?set[ \t]+serviceGroup[ \t].*`)
+	if err != nil {
+		return nil, err
+	}
+	for _, setServiceGroupLine := range setServiceGroupLines {
+		line := strings.TrimSpace(setServiceGroupLine.Text)
+		unset := strings.HasPrefix(line, "un")
+		serviceGroupLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "set serviceGroup ")
+		name, options, err := ExtractName(serviceGroupLine)
+		if err != nil {
+			return nil, newParseError(setServiceGroupLine, "malformed set serviceGroup", err)
+		}
+		for ix := range serviceGroups {
+			if serviceGroups[ix].Name != name {
+				continue
+			}
+			if unset {
+				unsetServiceGroupOptions(&serviceGroups[ix], splitFields(options))
+			} else {
+				parseServiceGroupOptions(&serviceGroups[ix], splitFields(options))
+			}
+		}
+	}
+	servers, err := c.parseServerIndex(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, bindServiceGroupLine := range findConfigLines(file, bindServiceGroupRegexp) {
+		binding, ok, err := parseBindServiceGroupLine(bindServiceGroupLine, servers)
+		if err != nil && c.SkipMissingServers && errors.Is(err, ErrServerNotFound) {
+			logger.Printf("skipping service group line %q: %v", strings.TrimSpace(bindServiceGroupLine.Text), err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if binding.unbind {
+			unbindServiceGroupMember(serviceGroups, servers, binding.serviceGroup, binding.member)
+			continue
+		}
+		for ix := range serviceGroups {
+			if serviceGroups[ix].Name == binding.serviceGroup {
+				serviceGroups[ix].Members = append(serviceGroups[ix].Members, binding.member)
+			}
+		}
+	}
+	defaults, err := ParseGlobalDefaults(file)
+	if err != nil {
+		return nil, err
+	}
+	for ix := range serviceGroups {
+		if serviceGroups[ix].USIP == "" && defaults.USIP != "" {
+			serviceGroups[ix].USIP = defaults.USIP
+			serviceGroups[ix].USIPInherited = true
+		}
+	}
+	return serviceGroups, nil
+}
+
+// parseAddServiceGroupLine is a function that returns the ServiceGroup defined by an "add serviceGroup" line matched
+// from a NetScaler configuration, without any members.
+func parseAddServiceGroupLine(addServiceGroupLine ConfigLine) (ServiceGroup, error) {
+	serviceGroupLine := RemoveConfigKeywords(strings.TrimSpace(addServiceGroupLine.Text), "add serviceGroup ")
+	name, remainder, err := ExtractName(serviceGroupLine)
+	if err != nil {
+		return ServiceGroup{}, newParseError(addServiceGroupLine, "malformed add serviceGroup", err)
+	}
+	var serviceGroup ServiceGroup
+	serviceGroup.Name = name
+	serviceGroupLineArray := splitFields(remainder)
+	if len(serviceGroupLineArray) > 0 {
+		serviceGroup.RawProtocol = serviceGroupLineArray[0]
+		serviceGroup.Protocol = normalizeProtocol(serviceGroupLineArray[0])
+	}
+	parseServiceGroupOptions(&serviceGroup, serviceGroupLineArray)
+	serviceGroup.Members = []ServiceGroupMember{}
+	return serviceGroup, nil
+}
+
+// serviceGroupBinding is a data structure for a member bound to a service group by a "bind serviceGroup" line, or
+// unbound from it by an "unbind serviceGroup" line.
+type serviceGroupBinding struct {
+	serviceGroup string
+	member       ServiceGroupMember
+	unbind       bool
+}
+
+// parseBindServiceGroupLine is a function that returns the member bound or unbound by a "bind serviceGroup" or
+// "unbind serviceGroup" line matched from a NetScaler configuration, looking up its server in servers.  The returned
+// bool is false for the monitor and other option bindings, which do not bind a member.  The server of an unbound member
+// is not looked up, so only its name is set.
+func parseBindServiceGroupLine(bindServiceGroupLine ConfigLine, servers *ServerIndex) (serviceGroupBinding, bool,
+	error) {
+	line := strings.TrimSpace(bindServiceGroupLine.Text)
+	unbind := strings.HasPrefix(line, "un")
+	bindLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "bind serviceGroup ")
+	name, remainder, err := ExtractName(bindLine)
+	if err != nil {
+		return serviceGroupBinding{}, false, newParseError(bindServiceGroupLine, "malformed bind serviceGroup", err)
+	}
+	if remainder == "" || strings.HasPrefix(remainder, "-") {
+		return serviceGroupBinding{}, false, nil
+	}
+	serverName, remainder, err := ExtractName(remainder)
+	if err != nil {
+		return serviceGroupBinding{}, false, newParseError(bindServiceGroupLine, "malformed bind serviceGroup", err)
+	}
+	binding := serviceGroupBinding{serviceGroup: name, unbind: unbind}
+	if unbind {
+		binding.member.Name = serverName
+		if tokens := strings.Fields(remainder); len(tokens) > 0 {
+			binding.member.Port, err = ParsePort(tokens[0])
+		}
+	} else {
+		binding.member, err = buildServiceGroupMember(servers, serverName, strings.Fields(remainder))
+	}
+	if err != nil {
+		return serviceGroupBinding{}, false, newParseError(bindServiceGroupLine, "malformed bind serviceGroup", err)
+	}
+	return binding, true, nil
+}
+
+// buildServiceGroupMember is a function that returns the member of a service group bound to serverName.  A member
+// may be bound by IP address rather than by server name, in which case NetScaler creates a server named after the
+// address.
+func buildServiceGroupMember(servers *ServerIndex, serverName string, tokens []string) (ServiceGroupMember, error) {
+	var member ServiceGroupMember
+	server, err := lookupServer(servers, serverName)
+	if err != nil {
+		if AddressFamilyOf(serverName) == AddressFamilyNone {
+			return ServiceGroupMember{}, err
+		}
+		server = Server{Name: serverName, IPAddress: serverName}
+	}
+	member.Server = server
+	if len(tokens) > 0 {
+		member.Port, err = ParsePort(tokens[0])
+		if err != nil {
+			return ServiceGroupMember{}, err
+		}
+	}
+	return member, nil
+}
+
+// unbindServiceGroupMember is a function that removes the member with the server name and port of unbound from the
+// service group with the given name, as an "unbind serviceGroup" line does.
+func unbindServiceGroupMember(serviceGroups []ServiceGroup, servers *ServerIndex, name string,
+	unbound ServiceGroupMember) {
+	for ix := range serviceGroups {
+		if serviceGroups[ix].Name != name {
+			continue
+		}
+		kept := serviceGroups[ix].Members[:0]
+		for _, member := range serviceGroups[ix].Members {
+			if servers.key(member.Name) != servers.key(unbound.Name) || member.Port != unbound.Port {
+				kept = append(kept, member)
+			}
+		}
+		serviceGroups[ix].Members = kept
+	}
+}
+
+// parseServiceGroupOptions is a function that reads the options of interest from the tokens of a service group line
+// and stores them on the service group.  As for services, the first occurrence of a repeated option wins.
+func parseServiceGroupOptions(serviceGroup *ServiceGroup, tokens []string) {
+	seen := make(map[string]bool)
+	for ix, token := range tokens {
+		if repeatedOption(seen, token) {
+			continue
+		}
+		switch token {
+		case "-usip":
+			serviceGroup.USIP = optionValue(tokens, ix)
+		case "-state":
+			serviceGroup.State = strings.ToUpper(optionValue(tokens, ix))
+		}
+	}
+}
+
+// unsetServiceGroupOptions is a function that returns the options of interest named by the tokens of an
+// "unset serviceGroup" line to their defaults, as UnsetServiceOptions does for services.
+func unsetServiceGroupOptions(serviceGroup *ServiceGroup, tokens []string) {
+	for _, token := range tokens {
+		switch token {
+		case "-usip":
+			serviceGroup.USIP = ""
+		case "-state":
+			serviceGroup.State = ""
+		}
+	}
+}
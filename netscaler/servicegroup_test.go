@@ -0,0 +1,46 @@
+package netscaler
+
+import (
+	"reflect"
+	"testing"
+)
+
+// memberAddresses is a function that returns the IP address and port of each member of a service group, in order.
+func memberAddresses(serviceGroup ServiceGroup) []string {
+	var addresses []string
+	for _, member := range serviceGroup.Members {
+		addresses = append(addresses, member.IPAddress+":"+FormatPort(member.Port))
+	}
+	return addresses
+}
+
+func TestGetServiceGroups(t *testing.T) {
+	serviceGroups, err := GetServiceGroups(fixture("servicegroups.conf"))
+	if err != nil {
+		t.Fatalf("GetServiceGroups() error = %v", err)
+	}
+	if len(serviceGroups) != 2 {
+		t.Fatalf("GetServiceGroups() returned %d service groups, want 2", len(serviceGroups))
+	}
+	sg1, sg2 := serviceGroups[0], serviceGroups[1]
+	if sg1.Name != "sg1" || sg1.Protocol != "HTTP" || sg1.USIP != "YES" {
+		t.Errorf("service group sg1 = %+v, want HTTP with usip YES", sg1)
+	}
+	want := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.9:80"}
+	if got := memberAddresses(sg1); !reflect.DeepEqual(got, want) {
+		t.Errorf("service group sg1: members = %v, want %v", got, want)
+	}
+	if sg2.USIP != "" || sg2.State != StateDisabled {
+		t.Errorf("service group sg2: USIP = %q, State = %q, want unset and %s", sg2.USIP, sg2.State, StateDisabled)
+	}
+}
+
+func TestServiceGroupServices(t *testing.T) {
+	services, err := ParseAllServices(readFixture(t, "servicegroups.conf"))
+	if err != nil {
+		t.Fatalf("ParseAllServices() error = %v", err)
+	}
+	if got := len(FilterByUSIP(services, USIPModeYes)); got != 3 {
+		t.Errorf("ParseAllServices() has %d services with USIP enabled, want the 3 members of sg1", got)
+	}
+}
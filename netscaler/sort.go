@@ -0,0 +1,54 @@
+package netscaler
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// The keys accepted by SortServices.
+const (
+	SortByName     = "name"
+	SortByIP       = "ip"
+	SortByProtocol = "protocol"
+	SortByPort     = "port"
+)
+
+// SortServices is a function that sorts services in place by the key by, one of SortByName, SortByIP, SortByProtocol
+// or SortByPort.  IP addresses are compared numerically, with IPv4 before IPv6 and both before servers without an IP
+// address.  Services with equal keys keep their order.  An unknown key is an error and leaves services unchanged.
+func SortServices(services []Service, by string) error {
+	var less func(a, b Service) bool
+	switch by {
+	case SortByName:
+		less = func(a, b Service) bool { return a.Name < b.Name }
+	case SortByIP:
+		less = func(a, b Service) bool { return ipLess(a.Server.IPAddress, b.Server.IPAddress) }
+	case SortByProtocol:
+		less = func(a, b Service) bool { return a.Protocol < b.Protocol }
+	case SortByPort:
+		less = func(a, b Service) bool { return a.Port < b.Port }
+	default:
+		return fmt.Errorf("unknown sort key: %s", by)
+	}
+	sort.SliceStable(services, func(i, j int) bool { return less(services[i], services[j]) })
+	return nil
+}
+
+// ipLess is a function that reports whether the address a sorts before the address b.  Addresses that are not IP
+// addresses sort after those that are, and are compared as strings.
+func ipLess(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipA == nil && ipB == nil:
+		return a < b
+	case ipA == nil || ipB == nil:
+		return ipB == nil
+	}
+	v4A, v4B := ipA.To4() != nil, ipB.To4() != nil
+	if v4A != v4B {
+		return v4A
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16()) < 0
+}
@@ -0,0 +1,42 @@
+package netscaler
+
+import "testing"
+
+// sortServices is a function that returns services that are in a different order for each sort key.
+func sortServices() []Service {
+	return []Service{
+		NewService("charlie", Server{Name: "web1", IPAddress: "10.0.0.10"}, "TCP", 8080, ""),
+		NewService("alpha", Server{Name: "web2", IPAddress: "2001:db8::1"}, "SSL", 443, ""),
+		NewService("delta", Server{Name: "web3", Domain: "web.example.com"}, "HTTP", WildcardPort, ""),
+		NewService("bravo", Server{Name: "web4", IPAddress: "10.0.0.9"}, "HTTP", 80, ""),
+	}
+}
+
+func TestSortServices(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{by: SortByName, want: []string{"alpha", "bravo", "charlie", "delta"}},
+		{by: SortByIP, want: []string{"bravo", "charlie", "alpha", "delta"}},
+		{by: SortByProtocol, want: []string{"delta", "bravo", "alpha", "charlie"}},
+		{by: SortByPort, want: []string{"delta", "bravo", "alpha", "charlie"}},
+	}
+	for _, tt := range tests {
+		services := sortServices()
+		if err := SortServices(services, tt.by); err != nil {
+			t.Errorf("SortServices(%q) error = %v", tt.by, err)
+			continue
+		}
+		if got := serviceNames(services); !equalNames(got, tt.want) {
+			t.Errorf("SortServices(%q) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+	services := sortServices()
+	if err := SortServices(services, "usip"); err == nil {
+		t.Error(`SortServices("usip") error = nil, want an unknown sort key error`)
+	}
+	if got, want := serviceNames(services), serviceNames(sortServices()); !equalNames(got, want) {
+		t.Errorf(`SortServices("usip") reordered the services to %v, want %v`, got, want)
+	}
+}
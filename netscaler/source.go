@@ -0,0 +1,56 @@
+package netscaler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// fetchTimeout is how long fetching a configuration from a URL may take, including reading the body, before it fails.
+const fetchTimeout = 30 * time.Second
+
+// httpClient is the client used to fetch configurations from a URL.
+var httpClient = &http.Client{Timeout: fetchTimeout}
+
+// IsURL is a function that reports whether ref is an http or https URL rather than a file name.
+func IsURL(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
+
+// loadSource is a function that returns the contents of the configuration that ref refers to, fetching it when it is
+// a URL and reading it from disk otherwise.  Compressed contents are decompressed, line endings are normalized and a
+// leading byte order mark is removed.
+func loadSource(ref string) (string, error) {
+	if IsURL(ref) {
+		return fetchURL(ref)
+	}
+	file, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	file, err = Decompress(file)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return RemoveBOM(NormalizeLineEndings(string(file))), nil
+}
+
+// fetchURL is a function that returns the contents of the configuration served at url.  A response other than 200 OK
+// is an error.
+func fetchURL(url string) (string, error) {
+	response, err := httpClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, response.Status)
+	}
+	file, err := ReadConfig(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,70 @@
+package netscaler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newConfigServer is a function that returns a test server serving the testdata fixtures under /, which is closed when
+// the test ends.  A request for /slow only returns once the client gives up.
+func newConfigServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{ref: "http://configs.example.com/ns.conf", want: true},
+		{ref: "https://configs.example.com/ns.conf", want: true},
+		{ref: "ns.conf", want: false},
+		{ref: "/var/nsconfig/ns.conf", want: false},
+		{ref: "ftp://configs.example.com/ns.conf", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsURL(tt.ref); got != tt.want {
+			t.Errorf("IsURL(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestGetServicesURL(t *testing.T) {
+	server := newConfigServer(t)
+	want := getServices(t, "servers_services.conf")
+	for _, name := range []string{"servers_services.conf", "servers_services.conf.gz"} {
+		services, err := GetServices(server.URL + "/" + name)
+		if err != nil {
+			t.Errorf("GetServices(%s) error = %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(services, want) {
+			t.Errorf("GetServices(%s) = %+v, want %+v", name, services, want)
+		}
+	}
+	if _, err := GetServices(server.URL + "/nonexistent.conf"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("GetServices(nonexistent.conf) error = %v, want a 404 error", err)
+	}
+}
+
+func TestFetchURLTimeout(t *testing.T) {
+	server := newConfigServer(t)
+	original := httpClient
+	httpClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { httpClient = original }()
+	if _, err := fetchURL(server.URL + "/slow"); err == nil {
+		t.Error("fetchURL(/slow) error = nil, want a timeout")
+	}
+}
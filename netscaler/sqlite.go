@@ -0,0 +1,79 @@
+package netscaler
+
+import (
+	"database/sql"
+
+	// The pure Go driver is used so that the program still builds without cgo.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables written by WriteSQLite, replacing any written before.  Each service refers to its
+// server by name.
+const sqliteSchema = `
+DROP TABLE IF EXISTS services;
+DROP TABLE IF EXISTS servers;
+CREATE TABLE servers (
+	name       TEXT PRIMARY KEY,
+	ip_address TEXT,
+	domain     TEXT,
+	comment    TEXT
+);
+CREATE TABLE services (
+	id          INTEGER PRIMARY KEY,
+	name        TEXT NOT NULL,
+	server      TEXT REFERENCES servers (name),
+	protocol    TEXT,
+	port        INTEGER,
+	usip        TEXT,
+	state       TEXT,
+	source_file TEXT,
+	line_number INTEGER
+);`
+
+// WriteSQLite is a function that writes services, and the servers they use, to the SQLite database at path for ad hoc
+// querying.  The database is created if it does not exist, and the servers and services tables are replaced if it
+// does.  The rows are written in a single transaction, so a failed write leaves the database as it was.
+func WriteSQLite(path string, services []Service) (err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	for _, service := range services {
+		var server interface{}
+		if service.Server.Name != "" {
+			server = service.Server.Name
+			_, err := tx.Exec("INSERT OR IGNORE INTO servers (name, ip_address, domain, comment) VALUES (?, ?, ?, ?)",
+				service.Server.Name, service.Server.IPAddress, service.Server.Domain, service.Server.Comment)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("INSERT INTO services (name, server, protocol, port, usip, state, source_file, line_number) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?)", service.Name, server, service.Protocol, service.Port, service.USIP,
+			service.State, service.SourceFile, service.LineNumber)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package netscaler
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usip.db")
+	services := getServices(t, "server_comments.conf")
+	services = append(services, NewService("orphan", Server{}, "TCP", WildcardPort, ""))
+	// Writing twice checks that the tables are replaced rather than appended to.
+	for ix := 0; ix < 2; ix++ {
+		if err := WriteSQLite(path, services); err != nil {
+			t.Fatalf("WriteSQLite() error = %v", err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT s.name, COALESCE(s.server, ''), COALESCE(v.ip_address, ''), " +
+		"COALESCE(v.domain, ''), s.port, s.usip, s.line_number " +
+		"FROM services s LEFT JOIN servers v ON s.server = v.name ORDER BY s.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type row struct {
+		name, server, ip, domain string
+		port                     int
+		usip                     string
+		line                     int
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.name, &r.server, &r.ip, &r.domain, &r.port, &r.usip, &r.line); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []row{
+		{name: "svc1", server: "web1", ip: "10.0.0.1", port: 80, usip: "YES", line: 4},
+		{name: "svc2", server: "web2", domain: "web.example.com", port: 80, usip: "YES", line: 5},
+		{name: "svc3", server: "web3", ip: "10.0.0.3", port: 80, usip: "YES", line: 6},
+		{name: "orphan", port: WildcardPort},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteSQLite() rows = %+v, want %+v", got, want)
+	}
+	var comment string
+	if err := db.QueryRow("SELECT comment FROM servers WHERE name = 'web1'").Scan(&comment); err != nil {
+		t.Fatal(err)
+	}
+	if want := "moved from 10.9.9.9 in 2019"; comment != want {
+		t.Errorf("server web1 comment = %q, want %q", comment, want)
+	}
+}
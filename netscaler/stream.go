@@ -0,0 +1,129 @@
+package netscaler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// maxLineLength is the length of the longest configuration line ScanServices accepts.
+const maxLineLength = 1024 * 1024
+
+// serviceLineRegexp matches the lines that applyServiceLines reads, so that ScanServices only passes it the lines that
+// can change a service.
+var serviceLineRegexp = regexp.MustCompile(`^[ \t]*(?:un)?` +
+	`(?:set[ \t]+service|bind[ \t]+service|bind[ \t]+ssl[ \t]+service|bind[ \t]+lb[ \t]+vserver)[ \t]`)
+
+// globalLineRegexp matches the lines that GlobalDefaults reads.
+var globalLineRegexp = regexp.MustCompile(globalLinePattern)
+
+// ScanServices is a function that returns an array of Load Balancing services read line by line from r.  It is meant
+// for configurations too large to read into memory at once.
+func ScanServices(r io.Reader) ([]Service, error) {
+	return ParserConfig{}.ScanServices(context.Background(), r)
+}
+
+// ScanServices is a method that returns the same Load Balancing services as ParseServices, but reads r one line at a
+// time so that memory use grows with the number of servers and services rather than with the size of the
+// configuration.  A gzip-compressed configuration is decompressed as it is read, and each line is decoded from the
+// Encoding.
+//
+// Each line is handled as it is read, so a line can only refer to servers and services defined above it.  This is
+// always the case for configurations saved by the NetScaler, which adds servers before the services that use them and
+// services before any "set service" or "bind" lines for them.  Reading stops once the Limit is reached, so lines
+// further on that change the services already read are not applied.
+func (c ParserConfig) ScanServices(ctx context.Context, r io.Reader) ([]Service, error) {
+	serverRegexp, err := regexp.Compile(c.serverPattern())
+	if err != nil {
+		return nil, err
+	}
+	serviceRegexp, err := regexp.Compile(c.servicePattern())
+	if err != nil {
+		return nil, err
+	}
+	reader, err := decompressReader(r)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineLength)
+	servers := newServerIndex(nil, c.CaseInsensitiveNames)
+	var services []Service
+	indexes := make(serviceIndexes)
+	var defaults GlobalDefaults
+	var lineNumber int
+scan:
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// A classic Mac line ending is a lone \r, which the scanner does not split on.
+		for _, line := range strings.Split(scanner.Text(), "\r") {
+			lineNumber++
+			if lineNumber == 1 {
+				line = RemoveBOM(line)
+			}
+			line, err := Decode(line, c.Encoding)
+			if err != nil {
+				return nil, err
+			}
+			if isComment(line) {
+				continue
+			}
+			if text := serverRegexp.FindString(line); text != "" {
+				servers.addLine(ConfigLine{Text: text, Number: lineNumber})
+			}
+			if text := serviceRegexp.FindString(line); text != "" {
+				service, ok, err := c.parseAddServiceLine(ConfigLine{Text: text, Number: lineNumber}, servers)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					indexes.add(service.Name, len(services))
+					services = append(services, service)
+				}
+				if c.limitReached(len(services)) {
+					break scan
+				}
+			}
+			if serviceLineRegexp.MatchString(line) {
+				if err := applyServiceLines(line, services, indexes); err != nil {
+					// applyServiceLines only sees this line, so its errors give line 1.
+					var parseErr *ParseError
+					if errors.As(err, &parseErr) {
+						parseErr.Line = lineNumber
+					}
+					return nil, err
+				}
+			}
+			if globalLineRegexp.MatchString(line) {
+				defaults.applyLine(line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	applyGlobalDefaults(services, defaults)
+	c.applyHook(services)
+	logServices(services)
+	return services, nil
+}
+
+// decompressReader is a function that returns a reader of the decompressed contents of r when it is gzip-compressed,
+// and a reader of r unchanged otherwise.  As with Decompress, compression is detected from the gzip magic bytes.
+func decompressReader(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return reader, nil
+	}
+	return gzip.NewReader(reader)
+}
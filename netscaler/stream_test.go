@@ -0,0 +1,101 @@
+package netscaler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cancellingReader is a data structure for a reader that returns first, then calls cancel before returning the rest,
+// so that a parse is cancelled partway through its input.
+type cancellingReader struct {
+	first, rest io.Reader
+	cancel      context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	if n, err := r.first.Read(p); err != io.EOF {
+		return n, err
+	}
+	r.cancel()
+	return r.rest.Read(p)
+}
+
+func TestScanServicesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancellingReader{
+		first:  strings.NewReader(generateConfig(10, 10)),
+		rest:   strings.NewReader(strings.Repeat("add service late web1 HTTP 80\n", 10)),
+		cancel: cancel,
+	}
+	services, err := ParserConfig{}.ScanServices(ctx, r)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanServices() = %d services, error %v, want %v", len(services), err, context.Canceled)
+	}
+}
+
+func TestScanServices(t *testing.T) {
+	for _, name := range []string{"servers_services.conf", "servers_services.conf.gz", "vservers.conf", "certkeys.conf",
+		"usip_set.conf"} {
+		file, err := os.Open(fixture(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		services, err := ScanServices(file)
+		file.Close()
+		if err != nil {
+			t.Errorf("ScanServices(%s) error = %v", name, err)
+			continue
+		}
+		if want := getServices(t, name); !reflect.DeepEqual(services, want) {
+			t.Errorf("ScanServices(%s) = %+v, want %+v", name, services, want)
+		}
+	}
+}
+
+// writeConfig is a function that writes a generated configuration of servers and services to a temporary file and
+// returns its path.
+func writeConfig(b *testing.B, servers, services int) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "ns.conf")
+	if err := os.WriteFile(path, []byte(generateConfig(servers, services)), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkGetServicesFile reads the whole configuration into memory before parsing it, for comparison of its
+// allocations with BenchmarkScanServicesFile.
+func BenchmarkGetServicesFile(b *testing.B) {
+	path := writeConfig(b, 5000, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		if _, err := GetServices(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanServicesFile(b *testing.B) {
+	path := writeConfig(b, 5000, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for ix := 0; ix < b.N; ix++ {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = ScanServices(file)
+		file.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
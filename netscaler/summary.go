@@ -0,0 +1,34 @@
+package netscaler
+
+import (
+	"fmt"
+)
+
+// Summary is a data structure for the counts reported at the end of a run.
+type Summary struct {
+	Services int
+	USIP     int
+	Files    int
+}
+
+// Summarize is a function that counts services, the services with USIP enabled and the distinct files they were
+// read from.  Services read from stdin count as a single file.
+func Summarize(services []Service) Summary {
+	var summary Summary
+	files := make(map[string]bool)
+	for _, service := range services {
+		summary.Services++
+		if service.HasUSIP() {
+			summary.USIP++
+		}
+		files[service.SourceFile] = true
+	}
+	summary.Files = len(files)
+	return summary
+}
+
+// String is a method that returns the summary as a single line, such as
+// "parsed 412 services, 37 with USIP enabled, 3 files".
+func (s Summary) String() string {
+	return fmt.Sprintf("parsed %d services, %d with USIP enabled, %d files", s.Services, s.USIP, s.Files)
+}
@@ -0,0 +1,24 @@
+package netscaler
+
+import (
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	services := usipServices()
+	services[0].SourceFile = "a.conf"
+	services[1].SourceFile = "b.conf"
+	services[2].SourceFile = "b.conf"
+	services[3].SourceFile = "c.conf"
+	got := Summarize(services)
+	want := Summary{Services: 4, USIP: 2, Files: 3}
+	if got != want {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+	if got, want := got.String(), "parsed 4 services, 2 with USIP enabled, 3 files"; got != want {
+		t.Errorf("Summary.String() = %q, want %q", got, want)
+	}
+	if got := Summarize(nil); got != (Summary{}) {
+		t.Errorf("Summarize(nil) = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,132 @@
+package netscaler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Issue is a data structure for a problem found by Validate.  Line is the 1-based number of the line the problem was
+// found on, or 0 when it does not belong to a single line.
+type Issue struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// String is a method that returns the issue as a single line, prefixed with its line number when it has one.
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// Validate is a function that accepts a file name as a parameter and returns every problem found while parsing it,
+// such as malformed lines, invalid ports, unknown protocols, missing servers and redefined servers.  Service group
+// members and virtual servers are checked as well as services.  Unlike the parse
+// functions it does not stop at the first malformed line.  The error is only for a file that cannot be read.
+func Validate(fileName string) ([]Issue, error) {
+	return ParserConfig{}.Validate(fileName)
+}
+
+// Validate is a method that returns every problem found while parsing a file, as the Validate function does, once it
+// is decoded from the Encoding.
+func (c ParserConfig) Validate(fileName string) ([]Issue, error) {
+	file, err := c.getFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateConfig(file)
+}
+
+// ValidateConfig is a function that returns every problem found while parsing the contents of a NetScaler
+// configuration, as Validate does for a file.  The issues are ordered by line, after those that do not belong to a
+// single line.
+func ValidateConfig(file string) ([]Issue, error) {
+	config := ParserConfig{}
+	file = NormalizeLineEndings(file)
+	var issues []Issue
+	addServerLines, err := GetConfigLines(file, config.serverPattern())
+	if err != nil {
+		return nil, err
+	}
+	var servers []Server
+	for _, addServerLine := range addServerLines {
+		server, err := parseAddServerLine(addServerLine)
+		if err != nil {
+			issues = append(issues, newIssue(addServerLine.Number, err))
+			continue
+		}
+		servers = append(servers, server)
+	}
+	for _, warning := range validateServers(servers) {
+		issues = append(issues, Issue{Message: warning.String()})
+	}
+	index := NewServerIndex(servers)
+	addServiceLines, err := GetConfigLines(file, config.servicePattern())
+	if err != nil {
+		return nil, err
+	}
+	var services []Service
+	var serviceLines []int
+	for _, addServiceLine := range addServiceLines {
+		serviceLine := RemoveConfigKeywords(addServiceLine.Text, "add service ")
+		service, ok, err := ParseServiceLine(serviceLine, index)
+		if err != nil {
+			issues = append(issues, Issue{Line: addServiceLine.Number, Message: "malformed add service: " + err.Error()})
+			continue
+		}
+		if !ok {
+			issues = append(issues, Issue{Line: addServiceLine.Number, Message: "add service line not understood"})
+			continue
+		}
+		services = append(services, service)
+		serviceLines = append(serviceLines, addServiceLine.Number)
+	}
+	// The lines changing services are applied one at a time, as ScanServices applies them, so that a malformed line
+	// does not stop the lines after it from being checked.
+	indexes := newServiceIndexes(services)
+	for ix, line := range strings.Split(RemoveComments(file), "\n") {
+		if !serviceLineRegexp.MatchString(line) {
+			continue
+		}
+		if err := applyServiceLines(line, services, indexes); err != nil {
+			issues = append(issues, newIssue(ix+1, err))
+		}
+	}
+	for _, addServiceGroupLine := range findConfigLines(file, addServiceGroupRegexp) {
+		if _, err := parseAddServiceGroupLine(addServiceGroupLine); err != nil {
+			issues = append(issues, newIssue(addServiceGroupLine.Number, err))
+		}
+	}
+	for _, bindServiceGroupLine := range findConfigLines(file, bindServiceGroupRegexp) {
+		if _, _, err := parseBindServiceGroupLine(bindServiceGroupLine, index); err != nil {
+			issues = append(issues, newIssue(bindServiceGroupLine.Number, err))
+		}
+	}
+	for _, addVServerLine := range findConfigLines(file, addVServerRegexp) {
+		if _, err := parseAddVServerLine(addVServerLine); err != nil {
+			issues = append(issues, newIssue(addVServerLine.Number, err))
+		}
+	}
+	for ix, service := range services {
+		for _, warning := range service.Warnings {
+			message := fmt.Sprintf("service %q: %s", service.Name, warning)
+			issues = append(issues, Issue{Line: serviceLines[ix], Message: message})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues, nil
+}
+
+// newIssue is a function that returns the Issue for err found on the given line.  The Issue adds the line number
+// itself, so only the reason of a ParseError is kept.
+func newIssue(line int, err error) Issue {
+	message := err.Error()
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		message = parseErr.Reason
+	}
+	return Issue{Line: line, Message: message}
+}
@@ -0,0 +1,72 @@
+package netscaler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	issues, err := Validate(fixture("issues.conf"))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := []struct {
+		line    int
+		message string
+	}{
+		{line: 0, message: "redefined with address 10.0.0.9"},
+		{line: 3, message: "missing address"},
+		{line: 5, message: "server not found: gone"},
+		{line: 6, message: `invalid port "99999"`},
+		{line: 7, message: "unknown service type"},
+		{line: 8, message: "invalid limit"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Validate() = %v, want %d issues", issues, len(want))
+	}
+	for ix, tt := range want {
+		if issues[ix].Line != tt.line || !strings.Contains(issues[ix].Message, tt.message) {
+			t.Errorf("Validate() issue %d = %v, want line %d containing %q", ix, issues[ix], tt.line, tt.message)
+		}
+	}
+	if issues, err := Validate(fixture("servers_services.conf")); err != nil || len(issues) != 0 {
+		t.Errorf("Validate(servers_services.conf) = %v, %v, want no issues", issues, err)
+	}
+	if _, err := Validate(fixture("nonexistent.conf")); err == nil {
+		t.Error("Validate(nonexistent.conf) error = nil, want an error")
+	}
+}
+
+func TestValidateBindings(t *testing.T) {
+	issues, err := Validate(fixture("validate_bindings.conf"))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	const invalidPort = `invalid port %q: must be a number between 1 and 65535 or *`
+	want := []Issue{
+		{Line: 2, Message: "malformed add service: service svc1: " + fmt.Sprintf(invalidPort, "abc")},
+		{Line: 4, Message: "malformed set service: empty name"},
+		{Line: 7, Message: "malformed bind serviceGroup: server not found: web2"},
+		{Line: 8, Message: "malformed add lb vserver: " + fmt.Sprintf(invalidPort, "http")},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Validate() = %v, want %v", issues, want)
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  string
+	}{
+		{issue: Issue{Line: 4, Message: "bad port"}, want: "line 4: bad port"},
+		{issue: Issue{Message: "redefined"}, want: "redefined"},
+	}
+	for _, tt := range tests {
+		if got := tt.issue.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.issue, got, tt.want)
+		}
+	}
+}
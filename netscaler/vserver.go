@@ -0,0 +1,167 @@
+package netscaler
+
+import (
+	"regexp"
+	"strings"
+)
+
+// VServer is a data structure for NetScaler Load Balancing virtual server data.
+type VServer struct {
+	Name      string    `json:"name"`
+	Protocol  string    `json:"protocol"`
+	IPAddress string    `json:"ip"`
+	Port      int       `json:"port"`
+	Services  []Service `json:"services"`
+}
+
+// vserverBinding is a data structure for a "bind lb vserver" line that binds a service to a virtual server, or an
+// "unbind lb vserver" line that removes the binding again.
+type vserverBinding struct {
+	vserver string
+	service string
+	unbind  bool
+}
+
+// GetVServers is a function that returns an array of Load Balancing virtual servers, each holding the services bound
+// to it.  It accepts a filename as a parameter.
+func GetVServers(fileName string) ([]VServer, error) {
+	file, err := GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	services, err := ParseServices(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseVServers(file, services)
+}
+
+// parseVServers is a function that returns the virtual servers defined within the contents of a NetScaler
+// configuration, linking the bound services by name.
+func parseVServers(file string, services []Service) ([]VServer, error) {
+	var vservers []VServer
+	for _, addVServerLine := range findConfigLines(file, addVServerRegexp) {
+		vserver, err := parseAddVServerLine(addVServerLine)
+		if err != nil {
+			return nil, err
+		}
+		vservers = append(vservers, vserver)
+	}
+	bindings, err := parseVServerBindings(file)
+	if err != nil {
+		return nil, err
+	}
+	indexes := newServiceIndexes(services)
+	for _, binding := range bindings {
+		for ix := range vservers {
+			if vservers[ix].Name != binding.vserver {
+				continue
+			}
+			if binding.unbind {
+				vservers[ix].Services = removeService(vservers[ix].Services, binding.service)
+				continue
+			}
+			for _, sx := range indexes[binding.service] {
+				vservers[ix].Services = append(vservers[ix].Services, services[sx])
+			}
+		}
+	}
+	return vservers, nil
+}
+
+// parseAddVServerLine is a function that returns the VServer defined by an "add lb vserver" line matched from a
+// NetScaler configuration, without any services.
+func parseAddVServerLine(addVServerLine ConfigLine) (VServer, error) {
+	vserverLine := RemoveConfigKeywords(strings.TrimSpace(addVServerLine.Text), "add lb vserver ")
+	name, remainder, err := ExtractName(vserverLine)
+	if err != nil {
+		return VServer{}, newParseError(addVServerLine, "malformed add lb vserver", err)
+	}
+	var vserver VServer
+	vserver.Name = name
+	vserverLineArray := strings.Fields(remainder)
+	if len(vserverLineArray) > 0 {
+		vserver.Protocol = vserverLineArray[0]
+	}
+	if len(vserverLineArray) > 1 {
+		vserver.IPAddress = vserverLineArray[1]
+	}
+	// Virtual servers that are not directly addressable are configured with port 0.
+	if len(vserverLineArray) > 2 && vserverLineArray[2] != "0" {
+		vserver.Port, err = ParsePort(vserverLineArray[2])
+		if err != nil {
+			return VServer{}, newParseError(addVServerLine, "malformed add lb vserver", err)
+		}
+	}
+	vserver.Services = []Service{}
+	return vserver, nil
+}
+
+// The "add lb vserver" and "bind lb vserver" lines are matched by more than one function, so their regular expressions
+// are compiled once.
+var (
+	addVServerRegexp  = regexp.MustCompile(`(?m)^[ \t]*add[ \t]+lb[ \t]+vserver[ \t].*`)
+	bindVServerRegexp = regexp.MustCompile(`(?m)^[ \t]*(?:un)?bind[ \t]+lb[ \t]+vserver[ \t].*`)
+)
+
+// parseVServerBindings is a function that returns the service bindings from the "bind lb vserver" lines within the
+// contents of a NetScaler configuration, in the order they appear.  Bindings of policies and other options are
+// ignored.
+func parseVServerBindings(file string) ([]vserverBinding, error) {
+	var bindings []vserverBinding
+	for _, bindVServerLine := range findConfigLines(file, bindVServerRegexp) {
+		line := strings.TrimSpace(bindVServerLine.Text)
+		unbind := strings.HasPrefix(line, "un")
+		bindLine := RemoveConfigKeywords(strings.TrimPrefix(line, "un"), "bind lb vserver ")
+		vserverName, remainder, err := ExtractName(bindLine)
+		if err != nil {
+			return nil, newParseError(bindVServerLine, "malformed bind lb vserver", err)
+		}
+		if remainder == "" || strings.HasPrefix(remainder, "-") {
+			continue
+		}
+		serviceName, _, err := ExtractName(remainder)
+		if err != nil {
+			return nil, newParseError(bindVServerLine, "malformed bind lb vserver", err)
+		}
+		bindings = append(bindings, vserverBinding{vserver: vserverName, service: serviceName, unbind: unbind})
+	}
+	return bindings, nil
+}
+
+// removeService is a function that returns services without those with the given name.
+func removeService(services []Service, name string) []Service {
+	kept := services[:0]
+	for _, service := range services {
+		if service.Name != name {
+			kept = append(kept, service)
+		}
+	}
+	return kept
+}
+
+// ApplyVServerBindings is a function that records on each service the names of the virtual servers it is bound to
+// by the "bind lb vserver" lines within the contents of a file.  An "unbind lb vserver" line removes the virtual server
+// again.
+func ApplyVServerBindings(file string, services []Service) error {
+	return applyVServerBindings(file, services, newServiceIndexes(services))
+}
+
+// applyVServerBindings is a function that applies the "bind lb vserver" lines within the contents of a file as
+// ApplyVServerBindings does, finding the services by name through indexes.
+func applyVServerBindings(file string, services []Service, indexes serviceIndexes) error {
+	bindings, err := parseVServerBindings(file)
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		for _, ix := range indexes[binding.service] {
+			if binding.unbind {
+				services[ix].VServers = removeName(services[ix].VServers, binding.vserver)
+				continue
+			}
+			services[ix].VServers = append(services[ix].VServers, binding.vserver)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package netscaler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetVServers(t *testing.T) {
+	vservers, err := GetVServers(fixture("vservers.conf"))
+	if err != nil {
+		t.Fatalf("GetVServers() error = %v", err)
+	}
+	want := map[string][]string{"vs1": {"svc1"}, "vs2": {"svc1"}}
+	if len(vservers) != len(want) {
+		t.Fatalf("GetVServers() returned %d virtual servers, want %d", len(vservers), len(want))
+	}
+	for _, vserver := range vservers {
+		if got := serviceNames(vserver.Services); !reflect.DeepEqual(got, want[vserver.Name]) {
+			t.Errorf("virtual server %q: services = %v, want %v", vserver.Name, got, want[vserver.Name])
+		}
+	}
+	if vservers[0].IPAddress != "192.0.2.1" || vservers[0].Port != 80 {
+		t.Errorf("virtual server vs1 = %+v, want 192.0.2.1 port 80", vservers[0])
+	}
+}
+
+func TestApplyVServerBindings(t *testing.T) {
+	services := getServices(t, "vservers.conf")
+	want := map[string][]string{"svc1": {"vs1", "vs2"}, "svc2": nil}
+	for _, service := range services {
+		if !equalNames(service.VServers, want[service.Name]) {
+			t.Errorf("service %q: VServers = %v, want %v", service.Name, service.VServers, want[service.Name])
+		}
+	}
+}
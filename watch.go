@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"usipProject/netscaler"
+)
+
+// fileWatcher is a data structure that detects changes to a file by polling its modification time, which works on
+// every file system.  stat is os.Stat, held as a field so that the file system can be replaced.
+type fileWatcher struct {
+	fileName string
+	stat     func(string) (fs.FileInfo, error)
+	modTime  time.Time
+	seen     bool
+}
+
+// changed is a method that reports whether the file has been modified since it was last checked, and is always true
+// for the first check.  A file that does not exist is reported as unchanged, as it is briefly when it is replaced by
+// renaming another file over it, so that it is checked again at the next poll.
+func (w *fileWatcher) changed() (bool, error) {
+	info, err := w.stat(w.fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if w.seen && info.ModTime().Equal(w.modTime) {
+		return false, nil
+	}
+	w.modTime = info.ModTime()
+	w.seen = true
+	return true, nil
+}
+
+// watch is a function that writes the services with USIP enabled in fileName to w, then checks the file every
+// interval and, each time it changes, writes the services that gained or lost USIP since the last parse.  A file that
+// cannot be parsed, as when it is caught half written, is reported to errw and the services of the last parse are
+// kept.  The file is parsed with the options of config.  watch returns when ctx is cancelled.
+func watch(ctx context.Context, config netscaler.ParserConfig, fileName string, interval time.Duration, w, errw io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	watcher := &fileWatcher{fileName: fileName, stat: os.Stat}
+	return watchTicks(ctx, config, watcher, ticker.C, w, errw)
+}
+
+// watchTicks is a function that runs the loop of watch, checking the file of watcher once at the start and then each
+// time a value is received from ticks.  Taking the ticks and the watcher as parameters lets the clock and the file
+// system be replaced.
+func watchTicks(ctx context.Context, config netscaler.ParserConfig, watcher *fileWatcher, ticks <-chan time.Time, w,
+	errw io.Writer) error {
+	fileName := watcher.fileName
+	var previous []netscaler.Service
+	var parsed bool
+	for {
+		changed, err := watcher.changed()
+		if err != nil {
+			return err
+		}
+		if changed {
+			services, err := config.GetAllServices(ctx, fileName)
+			if ctx.Err() != nil {
+				return nil
+			}
+			switch {
+			case err != nil:
+				fmt.Fprintf(errw, "%s: %v\n", fileName, err)
+			case !parsed:
+				usipServices := netscaler.FilterByUSIP(services, netscaler.USIPModeYes)
+				if err := netscaler.SortServices(usipServices, netscaler.SortByName); err != nil {
+					return err
+				}
+				if err := netscaler.WriteText(w, usipServices); err != nil {
+					return err
+				}
+			default:
+				added, removed := netscaler.DiffServices(previous, services)
+				if len(added) != 0 || len(removed) != 0 {
+					if err := netscaler.WriteDiff(w, added, removed); err != nil {
+						return err
+					}
+				}
+			}
+			if err == nil {
+				previous = services
+				parsed = true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticks:
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"usipProject/netscaler"
+)
+
+// fakeFileInfo is a data structure for the fs.FileInfo returned by a fake stat, of which only the modification time is
+// used.
+type fakeFileInfo struct {
+	modTime time.Time
+}
+
+func (i fakeFileInfo) Name() string       { return "ns.conf" }
+func (i fakeFileInfo) Size() int64        { return 0 }
+func (i fakeFileInfo) Mode() fs.FileMode  { return 0 }
+func (i fakeFileInfo) ModTime() time.Time { return i.modTime }
+func (i fakeFileInfo) IsDir() bool        { return false }
+func (i fakeFileInfo) Sys() interface{}   { return nil }
+
+// fakeStat is a data structure for what a fake stat reports: the modification time of the file, or that it is missing.
+type fakeStat struct {
+	modTime time.Time
+	missing bool
+}
+
+// fakeStatFunc is a function that returns a stat function that reports each of stats in turn, waiting for the next
+// one to be sent.  The test decides what each check of the file sees, and knows the check has started once the send
+// returns.
+func fakeStatFunc(stats <-chan fakeStat) func(string) (fs.FileInfo, error) {
+	return func(string) (fs.FileInfo, error) {
+		stat := <-stats
+		if stat.missing {
+			return nil, fs.ErrNotExist
+		}
+		return fakeFileInfo{modTime: stat.modTime}, nil
+	}
+}
+
+func TestFileWatcherChanged(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		modTime time.Time
+		missing bool
+		want    bool
+	}{
+		{name: "first check", modTime: start, want: true},
+		{name: "unchanged", modTime: start, want: false},
+		{name: "modified", modTime: start.Add(time.Second), want: true},
+		{name: "missing during replace", missing: true, want: false},
+		{name: "replaced unchanged", modTime: start.Add(time.Second), want: false},
+		{name: "replaced", modTime: start.Add(2 * time.Second), want: true},
+	}
+	stats := make(chan fakeStat, 1)
+	watcher := &fileWatcher{fileName: "ns.conf", stat: fakeStatFunc(stats)}
+	for _, tt := range tests {
+		stats <- fakeStat{modTime: tt.modTime, missing: tt.missing}
+		got, err := watcher.changed()
+		if err != nil {
+			t.Fatalf("%s: changed() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: changed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchTicks(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ns.conf")
+	writeConfig := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(fileName, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("add server web1 10.0.0.1\nadd service svc1 web1 HTTP 80 -usip YES\n")
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := make(chan fakeStat)
+	watcher := &fileWatcher{fileName: fileName, stat: fakeStatFunc(stats)}
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	var w, errw bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- watchTicks(ctx, netscaler.ParserConfig{}, watcher, ticks, &w, &errw)
+	}()
+	// The loop waits for each stat and tick, so the file is only changed while no check is reading it.
+	stats <- fakeStat{modTime: start}
+	ticks <- time.Time{}
+	writeConfig("add server web1 10.0.0.1\nadd service svc1 web1 HTTP 80 -usip NO\n" +
+		"add service svc2 web1 HTTP 8080 -usip YES\n")
+	stats <- fakeStat{modTime: start.Add(time.Second)}
+	ticks <- time.Time{}
+	stats <- fakeStat{missing: true}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("watchTicks() error = %v", err)
+	}
+	want := "svc1 web1 10.0.0.1\n" +
+		"Added USIP services (1):\nsvc2 web1 10.0.0.1\n\nRemoved USIP services (1):\nsvc1 web1 10.0.0.1\n"
+	if w.String() != want {
+		t.Errorf("watchTicks() wrote %q, want %q", w.String(), want)
+	}
+	if errw.Len() != 0 {
+		t.Errorf("watchTicks() reported %q, want nothing", errw.String())
+	}
+}